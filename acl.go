@@ -0,0 +1,260 @@
+package void
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+
+	"github.com/miekg/dns"
+)
+
+// ACLAction is the action taken for requests from denied clients.
+type ACLAction string
+
+const (
+	// REFUSE answers denied clients with REFUSED.
+	REFUSE ACLAction = "refuse"
+
+	// DROP silently drops the requests of denied clients.
+	DROP ACLAction = "drop"
+)
+
+// ACL is a list of client CIDRs (or IPs) which are allowed or denied
+// access to the resolver. Denied clients take precedence over allowed
+// clients, and when the allow list is empty all clients which are not
+// denied are allowed.
+type ACL struct {
+	Allow  []string
+	Deny   []string
+	Action ACLAction
+}
+
+// ListenerACL is an ACL which replaces the default ACL for the listeners
+// matching the listen addresses and their protocols, where listeners bound
+// to an unspecified IP (e.g. 0.0.0.0) match any local address on the same
+// port.
+type ListenerACL struct {
+	Listen []string
+	ACL    `mapstructure:",squash"`
+}
+
+// ACLConfig is the default ACL for all listeners along with the ACLs for
+// specific listeners.
+type ACLConfig struct {
+	ACL       `mapstructure:",squash"`
+	Listeners []ListenerACL
+}
+
+// Enabled indicates if any ACL is configured.
+func (c ACLConfig) Enabled() bool {
+	return len(c.Allow) > 0 || len(c.Deny) > 0 || len(c.Listeners) > 0
+}
+
+// AccessControl creates the client access control stage of the pipeline
+// from the ACL configuration.
+func AccessControl(
+	ctx context.Context,
+	logger Logger,
+	cfg ACLConfig,
+) (*Access, error) {
+	err := checkNil(ctx, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	def, err := cfg.ACL.rule()
+	if err != nil {
+		return nil, err
+	}
+
+	listeners := make([]*aclRule, 0, len(cfg.Listeners))
+	for _, l := range cfg.Listeners {
+		if len(l.Listen) == 0 {
+			return nil, fmt.Errorf("listener acl missing listen addresses")
+		}
+
+		rule, err := l.ACL.rule()
+		if err != nil {
+			return nil, err
+		}
+
+		rule.listen, err = ParseListen(l.Listen...)
+		if err != nil {
+			return nil, err
+		}
+
+		listeners = append(listeners, rule)
+	}
+
+	return &Access{
+		ctx:       ctx,
+		logger:    logger,
+		def:       def,
+		listeners: listeners,
+	}, nil
+}
+
+// Access enforces the client ACLs of the listeners.
+type Access struct {
+	ctx       context.Context
+	logger    Logger
+	def       *aclRule
+	listeners []*aclRule
+}
+
+func (a *Access) Intercept(
+	_ context.Context,
+	req *Request,
+) (*Request, bool) {
+	rule := a.rule(req.server, req.network)
+
+	client, err := netip.ParseAddrPort(req.client)
+	if err == nil && rule.permits(client.Addr().Unmap()) {
+		return req, true
+	}
+
+	a.logger.Debugw(
+		"denied",
+		"category", ACCESS,
+		"action", rule.action,
+		"client", req.client,
+		"server", req.server,
+	)
+
+	if rule.action == DROP {
+		req.Drop()
+		return nil, false
+	}
+
+	err = req.Fail(dns.RcodeRefused)
+	if err != nil {
+		a.logger.Errorw(
+			"failed to refuse request",
+			"category", ACCESS,
+			"client", req.client,
+			"server", req.server,
+			"error", err,
+		)
+	}
+
+	return nil, false
+}
+
+// rule returns the ACL of the listener the request was received on.
+func (a *Access) rule(server, network string) *aclRule {
+	local, err := netip.ParseAddrPort(server)
+	if err != nil {
+		return a.def
+	}
+
+	for _, rule := range a.listeners {
+		if rule.matches(local, network) {
+			return rule
+		}
+	}
+
+	return a.def
+}
+
+type aclRule struct {
+	listen []ListenAddr
+	allow  []netip.Prefix
+	deny   []netip.Prefix
+	action ACLAction
+}
+
+func (acl ACL) rule() (*aclRule, error) {
+	allow, err := ParsePrefixes(acl.Allow...)
+	if err != nil {
+		return nil, err
+	}
+
+	deny, err := ParsePrefixes(acl.Deny...)
+	if err != nil {
+		return nil, err
+	}
+
+	action := acl.Action
+	switch action {
+	case "":
+		action = REFUSE
+	case REFUSE, DROP:
+	default:
+		return nil, fmt.Errorf("invalid acl action [%s]", action)
+	}
+
+	return &aclRule{
+		allow:  allow,
+		deny:   deny,
+		action: action,
+	}, nil
+}
+
+// matches indicates if the local address and network of a request belong
+// to one of the listen addresses of the rule, where listeners sharing a
+// port are told apart by their transport (e.g. tcp-tls and quic on 853).
+func (r *aclRule) matches(local netip.AddrPort, network string) bool {
+	for _, l := range r.listen {
+		if l.Port != local.Port() {
+			continue
+		}
+
+		if l.Proto != "" && network != "" &&
+			string(l.Proto.transport()) != network {
+			continue
+		}
+
+		if l.IP == nil || l.IP.IsUnspecified() {
+			return true
+		}
+
+		ip, ok := netip.AddrFromSlice(l.IP)
+		if ok && ip.Unmap() == local.Addr().Unmap() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// permits indicates if the client is allowed by the rule.
+func (r *aclRule) permits(client netip.Addr) bool {
+	if contains(r.deny, client) {
+		return false
+	}
+
+	return len(r.allow) == 0 || contains(r.allow, client)
+}
+
+func contains(prefixes []netip.Prefix, ip netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParsePrefixes parses the CIDRs, where IPs are converted to single address
+// prefixes.
+func ParsePrefixes(cidrs ...string) ([]netip.Prefix, error) {
+	out := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		p, err := netip.ParsePrefix(cidr)
+		if err == nil {
+			out = append(out, p.Masked())
+			continue
+		}
+
+		ip, ipErr := netip.ParseAddr(cidr)
+		if ipErr != nil {
+			return nil, fmt.Errorf("invalid cidr [%s]: %w", cidr, err)
+		}
+
+		ip = ip.Unmap()
+		out = append(out, netip.PrefixFrom(ip, ip.BitLen()))
+	}
+
+	return out, nil
+}
@@ -0,0 +1,196 @@
+package void
+
+import (
+	"context"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func Test_Access_Intercept(t *testing.T) {
+	pctx, pcancel := context.WithCancel(context.Background())
+	defer pcancel()
+
+	cfg := ACLConfig{
+		ACL: ACL{
+			Allow: []string{"192.168.0.0/16", "fd00::/8", "127.0.0.1"},
+			Deny:  []string{"192.168.5.0/24"},
+		},
+		Listeners: []ListenerACL{
+			{
+				Listen: []string{"tcp-tls://0.0.0.0"},
+				ACL: ACL{
+					Allow:  []string{"203.0.113.0/24"},
+					Action: DROP,
+				},
+			},
+			{
+				Listen: []string{"10.0.0.1:5353"},
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		client  string
+		server  string
+		network string
+		pass    bool
+		rcode   int // -1 is dropped
+	}{
+		"allowed": {
+			client: "192.168.0.10:5353",
+			server: "192.168.0.2:53",
+			pass:   true,
+		},
+		"allowed-ip": {
+			client: "127.0.0.1:5353",
+			server: "127.0.0.1:53",
+			pass:   true,
+		},
+		"allowed-ipv6": {
+			client: "[fd00::10]:5353",
+			server: "[fd00::1]:53",
+			pass:   true,
+		},
+		"allowed-ipv4-mapped": {
+			client: "[::ffff:192.168.0.10]:5353",
+			server: "[::]:53",
+			pass:   true,
+		},
+		"not-allowed": {
+			client: "198.51.100.10:5353",
+			server: "192.168.0.2:53",
+			rcode:  dns.RcodeRefused,
+		},
+		"denied": {
+			client: "192.168.5.10:5353",
+			server: "192.168.0.2:53",
+			rcode:  dns.RcodeRefused,
+		},
+		"listener-allowed": {
+			client:  "203.0.113.10:5353",
+			server:  "198.51.100.1:853",
+			network: "tcp",
+			pass:    true,
+		},
+		"listener-dropped": {
+			client:  "192.168.0.10:5353",
+			server:  "198.51.100.1:853",
+			network: "tcp",
+			rcode:   -1,
+		},
+		"listener-other-proto": {
+			client:  "192.168.0.10:5353",
+			server:  "198.51.100.1:853",
+			network: "udp",
+			pass:    true,
+		},
+		"listener-other-proto-default": {
+			client:  "203.0.113.10:5353",
+			server:  "198.51.100.1:853",
+			network: "udp",
+			rcode:   dns.RcodeRefused,
+		},
+		"listener-open": {
+			client: "198.51.100.10:5353",
+			server: "10.0.0.1:5353",
+			pass:   true,
+		},
+		"listener-open-udp": {
+			client:  "198.51.100.10:5353",
+			server:  "10.0.0.1:5353",
+			network: "udp",
+			pass:    true,
+		},
+		"listener-open-tcp": {
+			client:  "198.51.100.10:5353",
+			server:  "10.0.0.1:5353",
+			network: "tcp",
+			pass:    true,
+		},
+		"listener-other-ip": {
+			client: "198.51.100.10:5353",
+			server: "10.0.0.2:5353",
+			rcode:  dns.RcodeRefused,
+		},
+		"invalid-client": {
+			client: "invalid",
+			server: "192.168.0.2:53",
+			rcode:  dns.RcodeRefused,
+		},
+	}
+
+	access, err := AccessControl(pctx, &NOOPLogger{}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(pctx)
+			defer cancel()
+
+			w := &TestWriter{}
+			req := &Request{
+				ctx:     ctx,
+				cancel:  cancel,
+				w:       w,
+				r:       Question(t, "test.example.tld.", dns.TypeA),
+				client:  test.client,
+				server:  test.server,
+				network: test.network,
+			}
+
+			_, pass := access.Intercept(ctx, req)
+			if pass != test.pass {
+				t.Fatalf("expected pass %v, got %v", test.pass, pass)
+			}
+
+			if test.pass {
+				return
+			}
+
+			if test.rcode < 0 {
+				if w.response != nil {
+					t.Fatal("expected request to be dropped")
+				}
+
+				if ctx.Err() == nil {
+					t.Fatal("expected request to be canceled")
+				}
+
+				return
+			}
+
+			if w.response == nil || w.response.Rcode != test.rcode {
+				t.Fatalf("expected rcode %s", dns.RcodeToString[test.rcode])
+			}
+		})
+	}
+}
+
+func Test_AccessControl_Invalid(t *testing.T) {
+	tests := map[string]ACLConfig{
+		"invalid-cidr": {
+			ACL: ACL{Allow: []string{"192.168.0.0/33"}},
+		},
+		"invalid-action": {
+			ACL: ACL{Deny: []string{"192.168.0.0/24"}, Action: "ignore"},
+		},
+		"missing-listen": {
+			Listeners: []ListenerACL{{ACL: ACL{Allow: []string{"10.0.0.0/8"}}}},
+		},
+		"invalid-listen": {
+			Listeners: []ListenerACL{{Listen: []string{"invalid://10.0.0.1"}}},
+		},
+	}
+
+	for name, cfg := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := AccessControl(context.Background(), &NOOPLogger{}, cfg)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package void
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd
+// socket activation, following stdin, stdout, and stderr.
+const listenFDsStart = 3
+
+// Socket is a pre-opened socket inherited by the process, such as from
+// systemd socket activation, which is served in place of binding a
+// listen address.
+type Socket struct {
+	Proto Protocol
+
+	// Listener is set for stream sockets (tcp, tcp-tls, https)
+	Listener net.Listener
+
+	// PacketConn is set for datagram sockets (udp, quic)
+	PacketConn net.PacketConn
+}
+
+// Addr returns the local address of the socket.
+func (s Socket) Addr() string {
+	switch {
+	case s.Listener != nil:
+		return s.Listener.Addr().String()
+	case s.PacketConn != nil:
+		return s.PacketConn.LocalAddr().String()
+	default:
+		return "<nil>"
+	}
+}
+
+func (s Socket) String() string {
+	return fmt.Sprintf("%s://%s", s.Proto, s.Addr())
+}
+
+// Activated returns the sockets passed to the process by systemd socket
+// activation (LISTEN_PID, LISTEN_FDS, and LISTEN_FDNAMES). No sockets are
+// returned when the process was not socket activated. The environment is
+// cleared so that the sockets are not inherited by child processes.
+func Activated() ([]Socket, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count < 1 {
+		return nil, nil
+	}
+
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	files := make([]*os.File, 0, count)
+	for i := 0; i < count; i++ {
+		name := ""
+		if i < len(names) {
+			name = names[i]
+		}
+
+		files = append(files, os.NewFile(uintptr(listenFDsStart+i), name))
+	}
+
+	return Sockets(files...)
+}
+
+// Sockets converts the open files into sockets, closing the files. The
+// protocol of each socket is the name of the file (FileDescriptorName= in
+// a systemd socket unit) when it is a supported protocol, otherwise it is
+// udp or tcp based on the type of the socket.
+func Sockets(files ...*os.File) ([]Socket, error) {
+	sockets := make([]Socket, 0, len(files))
+	var errs []error
+
+	for _, f := range files {
+		s, err := socket(f)
+		f.Close()
+
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		sockets = append(sockets, s)
+	}
+
+	if len(errs) > 0 {
+		for _, s := range sockets {
+			s.Close()
+		}
+
+		return nil, errors.Join(errs...)
+	}
+
+	return sockets, nil
+}
+
+func socket(f *os.File) (Socket, error) {
+	proto := Protocol(f.Name())
+
+	ln, err := net.FileListener(f)
+	if err == nil {
+		switch proto {
+		case TCP, TLS, HTTPS:
+		default:
+			proto = TCP
+		}
+
+		return Socket{Proto: proto, Listener: ln}, nil
+	}
+
+	pc, err := net.FilePacketConn(f)
+	if err == nil {
+		switch proto {
+		case UDP, QUIC:
+		default:
+			proto = UDP
+		}
+
+		return Socket{Proto: proto, PacketConn: pc}, nil
+	}
+
+	return Socket{}, fmt.Errorf("unsupported socket [%s]: %w", f.Name(), err)
+}
+
+// Close closes the underlying socket.
+func (s Socket) Close() error {
+	if s.Listener != nil {
+		return s.Listener.Close()
+	}
+
+	if s.PacketConn != nil {
+		return s.PacketConn.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,214 @@
+package void
+
+import (
+	"context"
+	"net"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const activatedEnv = "VOID_TEST_ACTIVATED"
+
+func Test_Sockets(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	udpFile, err := pc.(*net.UDPConn).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tcpFile, err := ln.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sockets, err := Sockets(udpFile, tcpFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"udp://" + pc.LocalAddr().String(),
+		"tcp://" + ln.Addr().String(),
+	}
+
+	if len(sockets) != len(expected) {
+		t.Fatalf("expected %d sockets, got %d", len(expected), len(sockets))
+	}
+
+	for i, s := range sockets {
+		defer s.Close()
+
+		if s.String() != expected[i] {
+			t.Fatalf("expected %s, got %s", expected[i], s)
+		}
+	}
+}
+
+func Test_Activated_NotActivated(t *testing.T) {
+	t.Setenv("LISTEN_PID", "1")
+	t.Setenv("LISTEN_FDS", "2")
+
+	sockets, err := Activated()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sockets) != 0 {
+		t.Fatalf("expected no sockets, got %d", len(sockets))
+	}
+
+	if os.Getenv("LISTEN_FDS") != "" {
+		t.Fatal("expected activation environment to be cleared")
+	}
+}
+
+// Test_Activated passes sockets to a child process in the same way as
+// systemd socket activation, where the child serves the sockets.
+func Test_Activated(t *testing.T) {
+	if os.Getenv(activatedEnv) != "" {
+		activatedChild(t)
+		return
+	}
+
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh is required to set LISTEN_PID for the child process")
+	}
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	udpFile, err := pc.(*net.UDPConn).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udpFile.Close()
+
+	tcpFile, err := ln.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcpFile.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	// exec preserves the pid of the shell so LISTEN_PID matches the child
+	//nolint:gosec // the test binary re-executes itself
+	cmd := exec.CommandContext(
+		ctx,
+		sh,
+		"-c",
+		`LISTEN_PID=$$ exec "$0" "$@"`,
+		os.Args[0],
+		"-test.run=^Test_Activated$",
+	)
+	cmd.Env = append(
+		os.Environ(),
+		activatedEnv+"=1",
+		"LISTEN_FDS=2",
+		// The sockets of a unit without FileDescriptorName= are named
+		// after the unit, as with deployment/systemd/void.socket
+		"LISTEN_FDNAMES=void.socket:void.socket",
+	)
+	cmd.ExtraFiles = []*os.File{udpFile, tcpFile}
+
+	err = cmd.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+
+	for _, proto := range []Protocol{UDP, TCP} {
+		addr := pc.LocalAddr().String()
+		if proto == TCP {
+			addr = ln.Addr().String()
+		}
+
+		c := &dns.Client{Net: string(proto), Timeout: time.Millisecond * 200}
+
+		var res *dns.Msg
+		for ctx.Err() == nil {
+			res, _, err = c.ExchangeContext(
+				ctx,
+				Question(t, "test.example.tld.", dns.TypeA),
+				addr,
+			)
+			if err == nil {
+				break
+			}
+		}
+
+		if err != nil {
+			t.Fatalf("%s: %v", proto, err)
+		}
+
+		if len(res.Answer) != 1 {
+			t.Fatalf("%s: expected 1 answer, got %d", proto, len(res.Answer))
+		}
+	}
+}
+
+// activatedChild serves the sockets inherited from the parent test.
+func activatedChild(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	sockets, err := Activated()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sockets) != 2 {
+		t.Fatalf("expected 2 sockets, got %d", len(sockets))
+	}
+
+	logger := &NOOPLogger{}
+	handler, requests := Convert(ctx, logger, false)
+	go answer(ctx, requests)
+
+	listeners, err := SocketListeners(
+		ctx,
+		logger,
+		dns.HandlerFunc(handler),
+		nil,
+		nil,
+		sockets...,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Serve(ctx, logger, listeners...)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
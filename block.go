@@ -1,7 +1,9 @@
-package main
+package void
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/miekg/dns"
 )
@@ -16,15 +18,29 @@ func BlockResolver(
 		return nil, err
 	}
 
+	for _, r := range records {
+		err = r.validate()
+		if err != nil {
+			return nil, fmt.Errorf("record [%s]: %w", r.Pattern, err)
+		}
+	}
+
 	m, err := NewMatcher(ctx, logger, records...)
 	if err != nil {
 		return nil, err
 	}
 
+	n, err := newNetworks(records...)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Block{
-		Matcher: m,
-		ctx:     ctx,
-		logger:  logger,
+		Matcher:  m,
+		ctx:      ctx,
+		logger:   logger,
+		networks: n,
+		now:      time.Now,
 	}, nil
 }
 
@@ -32,21 +48,54 @@ type Block struct {
 	*Matcher
 	ctx    context.Context
 	logger Logger
+
+	// networks block the upstream answers within the CIDR records
+	networks *networks
+
+	// mode is the default block mode, where modes are the block
+	// modes of the categories
+	mode  BlockMode
+	modes BlockModes
+
+	// schedules limit when the records of a category are applied
+	schedules Schedules
+	now       func() time.Time
+
+	// pauses disable blocking globally or for client groups
+	pauses *Pauses
 }
 
 func (b *Block) Intercept(
 	ctx context.Context,
 	req *Request,
 ) (*Request, bool) {
+	if b.pauses.Paused(req.group) {
+		req.nocache = true
+		return req, true
+	}
+
 	// Check for match
 	record := b.Match(ctx, req.Record())
 	if record == nil {
-		// No match continue to next resolver
-		return req, true
+		// No match continue to next resolver, inspecting the
+		// response for blocked cnames and addresses
+		return b.inspect(req), true
+	}
+
+	if !b.scheduled(req, record) {
+		b.logger.Debugw(
+			"schedule inactive",
+			"category", BLOCK,
+			"name", req.r.Question[0].Name,
+			"record", record,
+			"client", req.client,
+		)
+
+		return b.inspect(req), true
 	}
 
 	// Matched a blocked record
-	err := req.Block()
+	err := b.block(req, record)
 	if err != nil {
 		b.logger.Errorw(
 			"failed to block",
@@ -59,15 +108,125 @@ func (b *Block) Intercept(
 		)
 	}
 
+	b.log(b.event("matched", req, record))
+
+	return nil, false
+}
+
+// event creates the event of a blocked request for the matched record.
+func (b *Block) event(msg string, req *Request, record *Record) *Event {
+	return &Event{
+		Msg:      msg,
+		Name:     req.r.Question[0].Name,
+		Type:     dns.Type(req.r.Question[0].Qtype),
+		Client:   req.client,
+		Server:   req.server,
+		Record:   record,
+		Category: BLOCK,
+		Source:   record.Source,
+	}
+}
+
+func (b *Block) log(e *Event) {
 	b.logger.Infow(
-		"matched",
+		e.Msg,
 		"category", BLOCK,
-		"name", req.r.Question[0].Name,
-		"type", dns.Type(req.r.Question[0].Qtype),
-		"record", record,
-		"client", req.client,
-		"server", req.server,
+		"event", e,
 	)
+}
 
-	return nil, false
+// inspect wraps the writer of the request with a blockWriter so that
+// the response is inspected on the way back to the client.
+func (b *Block) inspect(req *Request) *Request {
+	req.w = &blockWriter{
+		block: b,
+		req:   req,
+		next:  req.w.WriteMsg,
+	}
+
+	return req
+}
+
+// blockWriter is a Writer which applies the block lists to the upstream
+// response, blocking responses with a CNAME to a blocked name and
+// removing the addresses within blocked networks.
+type blockWriter struct {
+	block *Block
+	req   *Request
+	next  func(*dns.Msg) error
+}
+
+func (w *blockWriter) WriteMsg(res *dns.Msg) error {
+	b := w.block
+	if res == nil {
+		return w.next(res)
+	}
+
+	if b.pauses.Paused(w.req.group) {
+		w.req.nocache = true
+		return w.next(res)
+	}
+
+	target, record := b.cloaked(w.req, res)
+	if record != nil {
+		b.logger.Debugw(
+			"cname cloaking",
+			"category", BLOCK,
+			"name", w.req.r.Question[0].Name,
+			"target", target,
+		)
+
+		b.log(b.event("matched cname", w.req, record))
+
+		return w.blocked(record)
+	}
+
+	res, blocked, addresses := b.filter(w.req, res)
+	if len(blocked) == 0 {
+		return w.next(res)
+	}
+
+	for _, a := range blocked {
+		b.log(b.event("matched ip", w.req, a.record))
+	}
+
+	if !addresses {
+		return w.blocked(blocked[0].record)
+	}
+
+	w.req.nocache = true
+
+	return w.next(res)
+}
+
+// blocked writes the block response for the record.
+func (w *blockWriter) blocked(record *Record) error {
+	w.req.nocache = true
+
+	return w.next(
+		w.block.modes.mode(record, w.block.mode).response(w.req.r),
+	)
+}
+
+// scheduled indicates if the schedule of the record is active, where
+// records without a schedule are always applied. The response of a request
+// matching a scheduled record is not cached, as it would outlive the
+// boundaries of the schedule.
+func (b *Block) scheduled(req *Request, record *Record) bool {
+	schedule := b.schedules.schedule(record)
+	if schedule == nil {
+		return true
+	}
+
+	req.nocache = true
+
+	return schedule.Active(b.now())
+}
+
+// block answers the request according to the block mode of the record,
+// which is not cached so that pauses and schedules apply to the next
+// request.
+func (b *Block) block(req *Request, record *Record) error {
+	req.nocache = true
+	return req.Block(b.modes.mode(record, b.mode))
 }
@@ -0,0 +1,94 @@
+package void
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func Test_Block_Intercept_Action(t *testing.T) {
+	pctx, pcancel := context.WithCancel(context.Background())
+	defer pcancel()
+
+	block, err := BlockResolver(pctx, &NOOPLogger{},
+		&Record{Pattern: "nxdomain.example.tld", Type: DIRECT},
+		&Record{Pattern: "nodata.example.tld", Type: DIRECT, Action: NODATA},
+		&Record{
+			Pattern: "redirect.example.tld",
+			Type:    DIRECT,
+			Action:  REDIRECT,
+			IP:      net.ParseIP("192.168.0.10"),
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		name    string
+		qtype   uint16
+		rcode   int
+		answers int
+	}{
+		"nxdomain": {
+			name:  "nxdomain.example.tld.",
+			qtype: dns.TypeA,
+			rcode: dns.RcodeNameError,
+		},
+		"nodata": {
+			name:  "nodata.example.tld.",
+			qtype: dns.TypeA,
+		},
+		"redirect": {
+			name:    "redirect.example.tld.",
+			qtype:   dns.TypeA,
+			answers: 1,
+		},
+		"redirect-other-type": {
+			name:  "redirect.example.tld.",
+			qtype: dns.TypeAAAA,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(pctx)
+			defer cancel()
+
+			w := &TestWriter{}
+			req := &Request{
+				ctx:    ctx,
+				cancel: cancel,
+				w:      w,
+				r:      Question(t, test.name, test.qtype),
+			}
+
+			_, pass := block.Intercept(ctx, req)
+			if pass {
+				t.Fatal("expected request to be blocked")
+			}
+
+			if w.response == nil {
+				t.Fatal("expected response")
+			}
+
+			if w.response.Rcode != test.rcode {
+				t.Fatalf(
+					"expected rcode %s, got %s",
+					dns.RcodeToString[test.rcode],
+					dns.RcodeToString[w.response.Rcode],
+				)
+			}
+
+			if len(w.response.Answer) != test.answers {
+				t.Fatalf(
+					"expected %d answers, got %d",
+					test.answers,
+					len(w.response.Answer),
+				)
+			}
+		})
+	}
+}
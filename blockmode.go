@@ -0,0 +1,189 @@
+package void
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// Action indicates how a request matching a blocked record is answered.
+type Action string
+
+const (
+	// NXDOMAIN answers that the domain does not exist, which is the
+	// default for records without an action.
+	NXDOMAIN Action = "nxdomain"
+
+	// NODATA answers that the domain exists without any records of
+	// the requested type.
+	NODATA Action = "nodata"
+
+	// REFUSED answers that the server refuses to resolve the domain.
+	REFUSED Action = "refused"
+
+	// NULL answers A requests with 0.0.0.0 and AAAA requests with ::,
+	// and other requests with NODATA.
+	NULL Action = "null"
+
+	// REDIRECT answers with the IP of the record, or the sinkhole
+	// addresses of the block mode, when it matches the requested type,
+	// otherwise with NODATA.
+	REDIRECT Action = "redirect"
+)
+
+func (a Action) String() string {
+	return string(a)
+}
+
+// BlockMode configures the response to blocked requests. The IPv4 and
+// IPv6 addresses are the sinkhole for the redirect action, and the TTL is
+// used for the synthesized answers, defaulting to DEFAULTTTL.
+type BlockMode struct {
+	Action Action
+	IPv4   string
+	IPv6   string
+	TTL    uint32
+
+	ipv4 net.IP
+	ipv6 net.IP
+}
+
+// Validate checks the action and parses the sinkhole addresses.
+func (m *BlockMode) Validate() error {
+	switch m.Action {
+	case "", NXDOMAIN, NODATA, REFUSED, NULL, REDIRECT:
+	default:
+		return fmt.Errorf("invalid block action [%s]", m.Action)
+	}
+
+	if m.IPv4 != "" {
+		m.ipv4 = net.ParseIP(m.IPv4).To4()
+		if m.ipv4 == nil {
+			return fmt.Errorf("invalid sinkhole ipv4 [%s]", m.IPv4)
+		}
+	}
+
+	if m.IPv6 != "" {
+		ip := net.ParseIP(m.IPv6)
+		if ip == nil || ip.To4() != nil {
+			return fmt.Errorf("invalid sinkhole ipv6 [%s]", m.IPv6)
+		}
+
+		m.ipv6 = ip
+	}
+
+	if m.Action == REDIRECT && m.ipv4 == nil && m.ipv6 == nil {
+		return fmt.Errorf("block action [%s] requires a sinkhole address", REDIRECT)
+	}
+
+	return nil
+}
+
+// redirect returns the mode redirecting to the ips of a record.
+func (m BlockMode) redirect(ips ...net.IP) BlockMode {
+	m.Action = REDIRECT
+	m.ipv4, m.ipv6 = nil, nil
+
+	for _, ip := range ips {
+		switch {
+		case ip == nil:
+		case ip.To4() != nil:
+			m.ipv4 = ip.To4()
+		default:
+			m.ipv6 = ip
+		}
+	}
+
+	return m
+}
+
+// response returns the block response to the request.
+func (m BlockMode) response(req *dns.Msg) *dns.Msg {
+	switch m.Action {
+	case NODATA:
+		return (&dns.Msg{}).SetReply(req)
+	case REFUSED:
+		return (&dns.Msg{}).SetRcode(req, dns.RcodeRefused)
+	case NULL:
+		return m.answer(req, net.IPv4zero.To4(), net.IPv6zero)
+	case REDIRECT:
+		return m.answer(req, m.ipv4, m.ipv6)
+	default:
+		return (&dns.Msg{}).SetRcode(req, dns.RcodeNameError)
+	}
+}
+
+// answer answers the request with the address of the requested type,
+// where the response has no answers for other types.
+func (m BlockMode) answer(req *dns.Msg, ipv4, ipv6 net.IP) *dns.Msg {
+	res := (&dns.Msg{}).SetReply(req)
+
+	ttl := m.TTL
+	if ttl == 0 {
+		ttl = DEFAULTTTL
+	}
+
+	hdr := dns.RR_Header{
+		Name:  req.Question[0].Name,
+		Class: dns.ClassINET,
+		Ttl:   ttl,
+	}
+
+	switch {
+	case req.Question[0].Qtype == dns.TypeA && ipv4 != nil:
+		hdr.Rrtype = dns.TypeA
+		res.Answer = append(res.Answer, &dns.A{Hdr: hdr, A: ipv4})
+	case req.Question[0].Qtype == dns.TypeAAAA && ipv6 != nil:
+		hdr.Rrtype = dns.TypeAAAA
+		res.Answer = append(res.Answer, &dns.AAAA{Hdr: hdr, AAAA: ipv6})
+	}
+
+	return res
+}
+
+// BlockModes are the block modes of the record categories.
+type BlockModes map[string]*BlockMode
+
+// Validate validates each of the block modes.
+func (b BlockModes) Validate() error {
+	for category, mode := range b {
+		if mode == nil {
+			continue
+		}
+
+		err := mode.Validate()
+		if err != nil {
+			return fmt.Errorf("category [%s]: %w", category, err)
+		}
+	}
+
+	return nil
+}
+
+// mode returns the block mode of the record, in order of precedence the
+// action of the record, the mode of its source, the mode of its category,
+// and otherwise the default mode.
+func (b BlockModes) mode(r *Record, def BlockMode) BlockMode {
+	mode := def
+	if m := b[strings.ToLower(r.Category)]; r.Category != "" && m != nil {
+		mode = *m
+	}
+
+	if r.Mode != nil {
+		mode = *r.Mode
+	}
+
+	switch r.Action {
+	case "":
+	case REDIRECT:
+		if r.IP != nil || r.IPv6 != nil {
+			mode = mode.redirect(r.IP, r.IPv6)
+		}
+	default:
+		mode.Action = r.Action
+	}
+
+	return mode
+}
@@ -0,0 +1,177 @@
+package void
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func Test_BlockMode_Response(t *testing.T) {
+	tests := map[string]struct {
+		mode  BlockMode
+		qtype uint16
+		rcode int
+		ip    string
+		ttl   uint32
+	}{
+		"default": {
+			qtype: dns.TypeA,
+			rcode: dns.RcodeNameError,
+		},
+		"nodata": {
+			mode:  BlockMode{Action: NODATA},
+			qtype: dns.TypeA,
+		},
+		"refused": {
+			mode:  BlockMode{Action: REFUSED},
+			qtype: dns.TypeA,
+			rcode: dns.RcodeRefused,
+		},
+		"null-a": {
+			mode:  BlockMode{Action: NULL, TTL: 60},
+			qtype: dns.TypeA,
+			ip:    "0.0.0.0",
+			ttl:   60,
+		},
+		"null-aaaa": {
+			mode:  BlockMode{Action: NULL},
+			qtype: dns.TypeAAAA,
+			ip:    "::",
+			ttl:   DEFAULTTTL,
+		},
+		"null-mx": {
+			mode:  BlockMode{Action: NULL},
+			qtype: dns.TypeMX,
+		},
+		"sinkhole-a": {
+			mode:  BlockMode{Action: REDIRECT, IPv4: "10.0.0.1", IPv6: "fd00::1"},
+			qtype: dns.TypeA,
+			ip:    "10.0.0.1",
+			ttl:   DEFAULTTTL,
+		},
+		"sinkhole-aaaa": {
+			mode:  BlockMode{Action: REDIRECT, IPv4: "10.0.0.1", IPv6: "fd00::1"},
+			qtype: dns.TypeAAAA,
+			ip:    "fd00::1",
+			ttl:   DEFAULTTTL,
+		},
+		"sinkhole-missing-aaaa": {
+			mode:  BlockMode{Action: REDIRECT, IPv4: "10.0.0.1"},
+			qtype: dns.TypeAAAA,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := test.mode.Validate()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res := test.mode.response(Question(t, "test.example.tld.", test.qtype))
+			if res.Rcode != test.rcode {
+				t.Fatalf(
+					"expected rcode %s, got %s",
+					dns.RcodeToString[test.rcode],
+					dns.RcodeToString[res.Rcode],
+				)
+			}
+
+			if test.ip == "" {
+				if len(res.Answer) != 0 {
+					t.Fatalf("expected no answers, got %v", res.Answer)
+				}
+
+				return
+			}
+
+			if len(res.Answer) != 1 {
+				t.Fatalf("expected 1 answer, got %d", len(res.Answer))
+			}
+
+			var ip net.IP
+			switch rr := res.Answer[0].(type) {
+			case *dns.A:
+				ip = rr.A
+			case *dns.AAAA:
+				ip = rr.AAAA
+			}
+
+			if !ip.Equal(net.ParseIP(test.ip)) {
+				t.Fatalf("expected ip %s, got %s", test.ip, ip)
+			}
+
+			if res.Answer[0].Header().Ttl != test.ttl {
+				t.Fatalf("expected ttl %d, got %d", test.ttl, res.Answer[0].Header().Ttl)
+			}
+		})
+	}
+}
+
+func Test_BlockMode_Validate(t *testing.T) {
+	tests := map[string]BlockMode{
+		"action":        {Action: "sinkhole"},
+		"ipv4":          {IPv4: "fd00::1"},
+		"ipv6":          {IPv6: "10.0.0.1"},
+		"redirect-addr": {Action: REDIRECT},
+	}
+
+	for name, mode := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := mode.Validate(); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func Test_BlockModes_Precedence(t *testing.T) {
+	modes := BlockModes(categories(BlockModes{
+		"Ads": {Action: NULL},
+	}))
+
+	def := BlockMode{Action: NODATA}
+
+	tests := map[string]struct {
+		record   *Record
+		expected Action
+	}{
+		"default": {
+			record:   &Record{},
+			expected: NODATA,
+		},
+		"category": {
+			record:   &Record{Category: "ads"},
+			expected: NULL,
+		},
+		"source": {
+			record:   &Record{Category: "ads", Mode: &BlockMode{Action: REFUSED}},
+			expected: REFUSED,
+		},
+		"record": {
+			record: &Record{
+				Category: "ads",
+				Mode:     &BlockMode{Action: REFUSED},
+				Action:   NXDOMAIN,
+			},
+			expected: NXDOMAIN,
+		},
+		"record-redirect": {
+			record: &Record{
+				Action: REDIRECT,
+				IP:     net.ParseIP("192.168.0.10"),
+			},
+			expected: REDIRECT,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mode := modes.mode(test.record, def)
+			if mode.Action != test.expected {
+				t.Fatalf("expected action %s, got %s", test.expected, mode.Action)
+			}
+		})
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package void
 
 import (
 	"context"
@@ -12,6 +12,21 @@ import (
 	"go.devnw.com/ttl"
 )
 
+// CacheResolver creates the cache stage of the pipeline which answers
+// requests from previous responses until their TTL expires.
+func CacheResolver(ctx context.Context, logger Logger) (*Cache, error) {
+	err := checkNil(ctx, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cache{
+		ctx:    ctx,
+		logger: logger,
+		cache:  ttl.NewCache[string, *dns.Msg](ctx, time.Minute, false),
+	}, nil
+}
+
 type Cache struct {
 	ctx    context.Context
 	logger Logger
@@ -21,28 +36,16 @@ type Cache struct {
 // Intercept is the cache intercept func which attempts to first pull
 // the response from the cache if it exists. If it is no longer in the
 // cache then the request is passed down the pipeline after wrapping
-// the request with an interceptor. The interceptor is responsible for
+// the request with a cacheWriter. The cacheWriter is responsible for
 // caching the response on the way back to the client.
 func (c *Cache) Intercept(
 	ctx context.Context,
 	req *Request,
 ) (*Request, bool) {
-	if len(req.r.Question) == 0 {
-		err := req.Block()
-		if err != nil {
-			c.logger.Errorw(
-				"invalid question",
-				"category", CACHE,
-				"request", req.String(),
-				"error", err,
-			)
-		}
-	}
-
 	r, ok := c.cache.Get(c.ctx, req.Key())
 	if !ok || r == nil {
 		// Add hook for final response to cache
-		req.w = &interceptor{
+		req.w = &cacheWriter{
 			ctx:    c.ctx,
 			cache:  c.cache,
 			logger: c.logger,
@@ -53,7 +56,13 @@ func (c *Cache) Intercept(
 		return req, true
 	}
 
-	err := req.Answer(r.SetReply(req.r))
+	// The cached response is shared so the reply is a copy, keeping
+	// the rcode of the cached response
+	res := r.Copy()
+	res.Id = req.r.Id
+	res.Question = req.r.Question
+
+	err := req.Answer(res)
 	if err != nil {
 		c.logger.Errorw(
 			"failed to set reply",
@@ -66,10 +75,10 @@ func (c *Cache) Intercept(
 	return req, false
 }
 
-// interceptor is a dns.ResponseWriter that caches the response
+// cacheWriter is a dns.ResponseWriter that caches the response
 // for future queries so that they are not re-requesting an updated
 // IP for an address that has already been queried.
-type interceptor struct {
+type cacheWriter struct {
 	ctx    context.Context
 	cache  *ttl.Cache[string, *dns.Msg]
 	logger Logger
@@ -78,7 +87,11 @@ type interceptor struct {
 	once   sync.Once
 }
 
-func (i *interceptor) WriteMsg(res *dns.Msg) (err error) {
+func (i *cacheWriter) WriteMsg(res *dns.Msg) (err error) {
+	if i.req.nocache {
+		return i.next(res)
+	}
+
 	i.once.Do(func() {
 		ttl := time.Second * DEFAULTTTL
 
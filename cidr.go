@@ -0,0 +1,172 @@
+package void
+
+import (
+	"context"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// parseCIDR parses a list of networks, one CIDR or IP per line, into
+// hosts which block the upstream answers within the networks. Comments
+// start with # or ; as used by lists such as the Spamhaus DROP list.
+//
+//	192.0.2.0/24 ; SBL000001
+//	198.51.100.7 # single address
+func parseCIDR(ctx context.Context, logger Logger, data []byte) Hosts {
+	hosts := Hosts{}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		select {
+		case <-ctx.Done():
+			return hosts
+		default:
+		}
+
+		var comment string
+		if i := strings.IndexAny(line, "#;"); i != -1 {
+			comment = strings.TrimSpace(line[i+1:])
+			line = line[:i]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		prefixes, err := ParsePrefixes(fields[0])
+		if err != nil {
+			logger.Debugw(
+				"invalid cidr",
+				"line", line,
+				"error", err,
+			)
+			continue
+		}
+
+		hosts = append(hosts, &Host{
+			Domain:  prefixes[0].String(),
+			Type:    CIDR,
+			Comment: comment,
+		})
+	}
+
+	return hosts
+}
+
+// networks indexes the CIDR records by their prefix, where an address
+// is matched by masking it to each of the prefix lengths.
+type networks struct {
+	prefixes map[netip.Prefix]*Record
+
+	// lengths are the distinct prefix lengths, longest first
+	lengths []int
+}
+
+func newNetworks(records ...*Record) (*networks, error) {
+	n := &networks{prefixes: make(map[netip.Prefix]*Record)}
+
+	lengths := make(map[int]bool)
+	for _, r := range records {
+		if r.Type != CIDR {
+			continue
+		}
+
+		prefixes, err := ParsePrefixes(r.Pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		n.prefixes[prefixes[0]] = r
+		lengths[prefixes[0].Bits()] = true
+	}
+
+	for l := range lengths {
+		n.lengths = append(n.lengths, l)
+	}
+
+	sort.Sort(sort.Reverse(sort.IntSlice(n.lengths)))
+
+	return n, nil
+}
+
+// match returns the record of the most specific network containing the
+// address.
+func (n *networks) match(ip netip.Addr) *Record {
+	if n == nil || len(n.prefixes) == 0 {
+		return nil
+	}
+
+	ip = ip.Unmap()
+	for _, l := range n.lengths {
+		if l > ip.BitLen() {
+			continue
+		}
+
+		p, err := ip.Prefix(l)
+		if err != nil {
+			continue
+		}
+
+		if r, ok := n.prefixes[p]; ok {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// blockedAnswer is an address answer within a blocked network.
+type blockedAnswer struct {
+	rr     dns.RR
+	record *Record
+}
+
+// filter removes the A and AAAA answers of the response which are within
+// the blocked networks, returning the filtered copy of the response and
+// the removed answers, along with whether any address answers remain.
+func (b *Block) filter(
+	req *Request,
+	res *dns.Msg,
+) (*dns.Msg, []blockedAnswer, bool) {
+	if b.networks == nil || len(b.networks.prefixes) == 0 {
+		return res, nil, true
+	}
+
+	var blocked []blockedAnswer
+	kept := make([]dns.RR, 0, len(res.Answer))
+	addresses := false
+
+	for _, rr := range res.Answer {
+		var ip netip.Addr
+		switch v := rr.(type) {
+		case *dns.A:
+			ip, _ = netip.AddrFromSlice(v.A)
+		case *dns.AAAA:
+			ip, _ = netip.AddrFromSlice(v.AAAA)
+		default:
+			kept = append(kept, rr)
+			continue
+		}
+
+		record := b.networks.match(ip)
+		if record == nil || !b.scheduled(req, record) {
+			kept = append(kept, rr)
+			addresses = true
+			continue
+		}
+
+		blocked = append(blocked, blockedAnswer{rr, record})
+	}
+
+	if len(blocked) == 0 {
+		return res, nil, true
+	}
+
+	filtered := res.Copy()
+	filtered.Answer = kept
+
+	return filtered, blocked, addresses
+}
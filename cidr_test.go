@@ -0,0 +1,170 @@
+package void
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func Test_Parse_CIDR(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	body := io.NopCloser(strings.NewReader(`
+; Spamhaus style comment
+192.0.2.0/24 ; SBL000001
+198.51.100.7 # single address
+2001:db8::/32
+10.1.2.3/8
+not-a-network
+`))
+
+	hosts := Parse(ctx, &NOOPLogger{}, CIDR, body)
+
+	expected := Hosts{
+		{Domain: "192.0.2.0/24", Type: CIDR, Comment: "SBL000001"},
+		{Domain: "198.51.100.7/32", Type: CIDR, Comment: "single address"},
+		{Domain: "2001:db8::/32", Type: CIDR},
+		{Domain: "10.0.0.0/8", Type: CIDR},
+	}
+
+	if len(hosts) != len(expected) {
+		t.Fatalf("expected %d hosts, got %d", len(expected), len(hosts))
+	}
+
+	for i, h := range hosts {
+		if h.Domain != expected[i].Domain ||
+			h.Type != expected[i].Type ||
+			h.Comment != expected[i].Comment {
+			t.Fatalf("expected host %+v, got %+v", expected[i], h)
+		}
+	}
+}
+
+func Test_Block_Networks(t *testing.T) {
+	pctx, pcancel := context.WithCancel(context.Background())
+	defer pcancel()
+
+	block, err := BlockResolver(pctx, &NOOPLogger{},
+		&Record{Pattern: "192.0.2.0/24", Type: CIDR},
+		&Record{Pattern: "2001:db8::/32", Type: CIDR},
+		&Record{Pattern: "10.0.0.0/8", Type: CIDR},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := func(ip string) dns.RR {
+		return &dns.A{
+			Hdr: dns.RR_Header{
+				Name:   "example.tld.",
+				Rrtype: dns.TypeA,
+				Class:  dns.ClassINET,
+				Ttl:    60,
+			},
+			A: net.ParseIP(ip),
+		}
+	}
+
+	aaaa := func(ip string) dns.RR {
+		return &dns.AAAA{
+			Hdr: dns.RR_Header{
+				Name:   "example.tld.",
+				Rrtype: dns.TypeAAAA,
+				Class:  dns.ClassINET,
+				Ttl:    60,
+			},
+			AAAA: net.ParseIP(ip),
+		}
+	}
+
+	tests := map[string]struct {
+		answer   []dns.RR
+		expected int
+		rcode    int
+		blocked  bool
+	}{
+		"unblocked": {
+			answer:   []dns.RR{a("198.51.100.1")},
+			expected: 1,
+		},
+		"blocked": {
+			answer:  []dns.RR{a("192.0.2.10")},
+			rcode:   dns.RcodeNameError,
+			blocked: true,
+		},
+		"blocked-ipv6": {
+			answer:  []dns.RR{aaaa("2001:db8::1")},
+			rcode:   dns.RcodeNameError,
+			blocked: true,
+		},
+		"mapped-ipv4": {
+			answer:  []dns.RR{aaaa("::ffff:10.1.2.3")},
+			rcode:   dns.RcodeNameError,
+			blocked: true,
+		},
+		"partial": {
+			answer:   []dns.RR{a("192.0.2.10"), a("198.51.100.1")},
+			expected: 1,
+			blocked:  true,
+		},
+		"empty": {},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(pctx)
+			defer cancel()
+
+			w := &TestWriter{}
+			req := &Request{
+				ctx:    ctx,
+				cancel: cancel,
+				w:      w,
+				r:      Question(t, "example.tld.", dns.TypeA),
+			}
+
+			req, pass := block.Intercept(ctx, req)
+			if !pass {
+				t.Fatal("expected request to pass")
+			}
+
+			res := (&dns.Msg{}).SetReply(req.r)
+			res.Answer = test.answer
+
+			err := req.Answer(res)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if req.nocache != test.blocked {
+				t.Fatalf("expected blocked %v, got %v", test.blocked, req.nocache)
+			}
+
+			if w.response.Rcode != test.rcode {
+				t.Fatalf(
+					"expected rcode %s, got %s",
+					dns.RcodeToString[test.rcode],
+					dns.RcodeToString[w.response.Rcode],
+				)
+			}
+
+			if len(w.response.Answer) != test.expected {
+				t.Fatalf(
+					"expected %d answers, got %v",
+					test.expected,
+					w.response.Answer,
+				)
+			}
+
+			// The upstream response is not modified
+			if len(res.Answer) != len(test.answer) {
+				t.Fatal("expected upstream response to be unchanged")
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package void
+
+import (
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// cloaked returns the first CNAME target of the answer chain which is
+// blocked along with its matching record. Trackers cloak a blocked name
+// behind a CNAME of a first-party subdomain which is not blocked.
+func (b *Block) cloaked(req *Request, res *dns.Msg) (string, *Record) {
+	for _, rr := range res.Answer {
+		cname, ok := rr.(*dns.CNAME)
+		if !ok {
+			continue
+		}
+
+		target := strings.TrimSuffix(cname.Target, ".")
+
+		// The request context is canceled once answered so the
+		// match uses the context of the block stage
+		record := b.Match(b.ctx, target)
+		if record != nil && b.scheduled(req, record) {
+			return target, record
+		}
+	}
+
+	return "", nil
+}
@@ -0,0 +1,179 @@
+package void
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func Test_Block_Cloaked(t *testing.T) {
+	pctx, pcancel := context.WithCancel(context.Background())
+	defer pcancel()
+
+	block, err := BlockResolver(pctx, &NOOPLogger{},
+		&Record{Pattern: "tracker.example.tld", Type: DIRECT},
+		&Record{Pattern: "*metrics.example.tld", Type: WILDCARD},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cname := func(name, target string) dns.RR {
+		return &dns.CNAME{
+			Hdr: dns.RR_Header{
+				Name:   name,
+				Rrtype: dns.TypeCNAME,
+				Class:  dns.ClassINET,
+				Ttl:    60,
+			},
+			Target: target,
+		}
+	}
+
+	a := &dns.A{
+		Hdr: dns.RR_Header{
+			Name:   "edge.cdn.tld.",
+			Rrtype: dns.TypeA,
+			Class:  dns.ClassINET,
+			Ttl:    60,
+		},
+		A: net.ParseIP("192.0.2.10"),
+	}
+
+	tests := map[string]struct {
+		answer  []dns.RR
+		blocked bool
+	}{
+		"direct": {
+			answer: []dns.RR{
+				cname("stats.first-party.tld.", "tracker.example.tld."),
+				a,
+			},
+			blocked: true,
+		},
+		"chain": {
+			answer: []dns.RR{
+				cname("stats.first-party.tld.", "edge.first-party.tld."),
+				cname("edge.first-party.tld.", "eu.metrics.example.tld."),
+				a,
+			},
+			blocked: true,
+		},
+		"unblocked": {
+			answer: []dns.RR{
+				cname("stats.first-party.tld.", "edge.cdn.tld."),
+				a,
+			},
+		},
+		"no-cname": {
+			answer: []dns.RR{a},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(pctx)
+			defer cancel()
+
+			w := &TestWriter{}
+			req := &Request{
+				ctx:    ctx,
+				cancel: cancel,
+				w:      w,
+				r:      Question(t, "stats.first-party.tld.", dns.TypeA),
+			}
+
+			req, pass := block.Intercept(ctx, req)
+			if !pass {
+				t.Fatal("expected request to pass")
+			}
+
+			res := (&dns.Msg{}).SetReply(req.r)
+			res.Answer = test.answer
+
+			err := req.Answer(res)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if w.response == nil {
+				t.Fatal("expected response")
+			}
+
+			if req.nocache != test.blocked {
+				t.Fatalf("expected blocked %v, got %v", test.blocked, req.nocache)
+			}
+
+			if !test.blocked {
+				if w.response != res {
+					t.Fatal("expected upstream response")
+				}
+
+				return
+			}
+
+			if w.response.Rcode != dns.RcodeNameError {
+				t.Fatalf(
+					"expected NXDOMAIN, got %s",
+					dns.RcodeToString[w.response.Rcode],
+				)
+			}
+
+			if len(w.response.Answer) != 0 {
+				t.Fatalf("expected no answers, got %v", w.response.Answer)
+			}
+		})
+	}
+}
+
+func Test_Block_Cloaked_Uncached(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cache, err := CacheResolver(ctx, &NOOPLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := BlockResolver(ctx, &NOOPLogger{},
+		&Record{Pattern: "tracker.example.tld", Type: DIRECT},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rctx, rcancel := context.WithCancel(ctx)
+	defer rcancel()
+
+	req := &Request{
+		ctx:    rctx,
+		cancel: rcancel,
+		w:      &TestWriter{},
+		r:      Question(t, "stats.first-party.tld.", dns.TypeA),
+	}
+
+	req, _ = cache.Intercept(ctx, req)
+	req, _ = block.Intercept(ctx, req)
+
+	res := (&dns.Msg{}).SetReply(req.r)
+	res.Answer = []dns.RR{&dns.CNAME{
+		Hdr: dns.RR_Header{
+			Name:   "stats.first-party.tld.",
+			Rrtype: dns.TypeCNAME,
+			Class:  dns.ClassINET,
+			Ttl:    60,
+		},
+		Target: "tracker.example.tld.",
+	}}
+
+	err = req.Answer(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := cache.cache.Get(ctx, req.Key()); ok {
+		t.Fatal("expected cloaked response to not be cached")
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"go.avoid.dev/void"
 )
 
 var (
@@ -20,7 +21,6 @@ var (
 const (
 	defaultConfigDir  = "/etc/void"
 	defaultConfigName = "config"
-	defaultPort       = 53
 )
 
 //nolint:gochecknoglobals // necessary for cobra root command
@@ -77,10 +77,72 @@ func init() {
 	root.PersistentFlags().Uint16P(
 		"port",
 		"p",
-		defaultPort,
+		void.DefaultPort,
 		"DNS listening port",
 	)
 
+	root.PersistentFlags().StringSliceP(
+		"listen",
+		"l",
+		[]string{},
+		"Listen addresses, overrides the port flags "+
+			"(example: 127.0.0.1:53, udp://[::1]:53, tcp-tls://192.168.0.2:853)",
+	)
+
+	root.PersistentFlags().Uint16(
+		"tls-port",
+		void.DefaultTLSPort,
+		"DNS-over-TLS listening port",
+	)
+
+	root.PersistentFlags().String(
+		"tls-cert",
+		"",
+		"DNS-over-TLS certificate path (enables DNS-over-TLS)",
+	)
+
+	root.PersistentFlags().String(
+		"tls-key",
+		"",
+		"DNS-over-TLS private key path",
+	)
+
+	root.PersistentFlags().Bool(
+		"https",
+		false,
+		"Enable DNS-over-HTTPS (requires --tls-cert and --tls-key)",
+	)
+
+	root.PersistentFlags().Uint16(
+		"https-port",
+		void.DefaultHTTPSPort,
+		"DNS-over-HTTPS listening port",
+	)
+
+	root.PersistentFlags().Bool(
+		"quic",
+		false,
+		"Enable DNS-over-QUIC (requires --tls-cert and --tls-key)",
+	)
+
+	root.PersistentFlags().Uint16(
+		"quic-port",
+		void.DefaultTLSPort,
+		"DNS-over-QUIC listening port",
+	)
+
+	root.PersistentFlags().Duration(
+		"tcp-timeout",
+		void.DefaultTCPTimeout,
+		"Idle timeout for TCP client connections",
+	)
+
+	root.PersistentFlags().Int(
+		"tcp-connections",
+		void.DefaultTCPConns,
+		"Maximum concurrent TCP client connections (0 for unlimited)",
+	)
+
 	root.PersistentFlags().StringSliceP(
 		"upstream",
 		"u",
@@ -88,7 +150,7 @@ func init() {
 			"tcp-tls://1.1.1.1:853",
 			"tcp-tls://1.0.0.1:853",
 		},
-		"Upstream DNS Servers",
+		"Upstream DNS Servers (example: udp://, tcp://, tcp-tls://, quic://)",
 	)
 
 	root.PersistentFlags().StringSlice(
@@ -102,6 +164,56 @@ func init() {
 		return
 	}
 
+	err = viper.BindPFlag("dns.listen", root.PersistentFlags().Lookup("listen"))
+	if err != nil {
+		return
+	}
+
+	err = viper.BindPFlag("dns.tls.port", root.PersistentFlags().Lookup("tls-port"))
+	if err != nil {
+		return
+	}
+
+	err = viper.BindPFlag("dns.tls.cert", root.PersistentFlags().Lookup("tls-cert"))
+	if err != nil {
+		return
+	}
+
+	err = viper.BindPFlag("dns.tls.key", root.PersistentFlags().Lookup("tls-key"))
+	if err != nil {
+		return
+	}
+
+	err = viper.BindPFlag("dns.https.enabled", root.PersistentFlags().Lookup("https"))
+	if err != nil {
+		return
+	}
+
+	err = viper.BindPFlag("dns.https.port", root.PersistentFlags().Lookup("https-port"))
+	if err != nil {
+		return
+	}
+
+	err = viper.BindPFlag("dns.quic.enabled", root.PersistentFlags().Lookup("quic"))
+	if err != nil {
+		return
+	}
+
+	err = viper.BindPFlag("dns.quic.port", root.PersistentFlags().Lookup("quic-port"))
+	if err != nil {
+		return
+	}
+
+	err = viper.BindPFlag("dns.tcp.timeout", root.PersistentFlags().Lookup("tcp-timeout"))
+	if err != nil {
+		return
+	}
+
+	err = viper.BindPFlag("dns.tcp.connections", root.PersistentFlags().Lookup("tcp-connections"))
+	if err != nil {
+		return
+	}
+
 	err = viper.BindPFlag("dns.upstream", root.PersistentFlags().Lookup("upstream"))
 	if err != nil {
 		return
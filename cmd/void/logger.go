@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+func configLogger() *zap.Logger {
+	jack := &lumberjack.Logger{}
+	err := viper.UnmarshalKey("logger", jack)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// If the lumberjack logger is not configured, then we will use the
+	// default zap logger. Otherwise, we will use the lumberjack logger
+	// as the output for the zap logger.
+	var w io.Writer = os.Stderr
+	if len(jack.Filename) > 0 {
+		if jack.Filename == ":stdout:" {
+			w = os.Stdout
+		} else {
+			w = jack
+		}
+	}
+
+	level := zapcore.ErrorLevel
+	if viper.IsSet("logger.level") {
+		l, err := zap.ParseAtomicLevel(viper.GetString("logger.level"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		level = l.Level()
+	}
+
+	ec := zap.NewProductionEncoderConfig()
+	dev := viper.GetBool("logger.dev")
+	if dev {
+		level = zapcore.DebugLevel
+		ec = zap.NewDevelopmentEncoderConfig()
+	}
+
+	enc := zapcore.NewJSONEncoder(ec)
+	if viper.GetString("logger.format") == "console" {
+		enc = zapcore.NewConsoleEncoder(ec)
+	}
+
+	core := zapcore.NewCore(
+		enc,
+		zapcore.AddSync(w),
+		level,
+	)
+
+	return zap.New(core, zap.WithCaller(
+		viper.GetBool("verbose"),
+	))
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.avoid.dev/void"
+)
+
+func init() {
+	viper.SetEnvPrefix("VOID")
+}
+
+func main() {
+	var err error
+	defer func() {
+		r := recover()
+		if r != nil {
+			err = errors.Join(fmt.Errorf("panic: %v", r), err)
+		}
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	}()
+
+	ctx, cancel := signal.NotifyContext(
+		context.Background(),
+		os.Interrupt,
+		syscall.SIGTERM,
+	)
+	defer cancel()
+
+	err = root.ExecuteContext(ctx)
+}
+
+//nolint:funlen // this contains the CLI flags
+func exec(cmd *cobra.Command, _ []string) {
+	ctx := cmd.Context()
+	logger := configLogger().Sugar()
+
+	var localSrcs void.Sources
+	err := viper.UnmarshalKey("dns.local", &localSrcs)
+	if err != nil {
+		logger.Fatalw(
+			"failed to unmarshal local sources",
+			"error", err,
+		)
+	}
+
+	var allowSrcs void.Sources
+	err = viper.UnmarshalKey("dns.allow", &allowSrcs)
+	if err != nil {
+		logger.Fatalw(
+			"failed to unmarshal allow sources",
+			"error", err,
+		)
+	}
+
+	var blockSrcs void.Sources
+	err = viper.UnmarshalKey("dns.block", &blockSrcs)
+	if err != nil {
+		logger.Fatalw(
+			"failed to unmarshal block sources",
+			"error", err,
+		)
+	}
+
+	cacheDir := viper.GetString("dns.cache")
+	if cacheDir != "" {
+		err := os.MkdirAll(cacheDir, 0o755)
+		if err != nil {
+			logger.Fatalw(
+				"failed to create cache directory",
+				"error", err,
+			)
+		}
+	}
+
+	var schedules void.Schedules
+	err = viper.UnmarshalKey("dns.schedules", &schedules)
+	if err != nil {
+		logger.Fatalw(
+			"failed to unmarshal schedules",
+			"error", err,
+		)
+	}
+
+	var blockMode void.BlockMode
+	err = viper.UnmarshalKey("dns.mode", &blockMode)
+	if err != nil {
+		logger.Fatalw(
+			"failed to unmarshal block mode",
+			"error", err,
+		)
+	}
+
+	var blockModes void.BlockModes
+	err = viper.UnmarshalKey("dns.modes", &blockModes)
+	if err != nil {
+		logger.Fatalw(
+			"failed to unmarshal category block modes",
+			"error", err,
+		)
+	}
+
+	var groupCfgs []groupConfig
+	err = viper.UnmarshalKey("dns.groups", &groupCfgs)
+	if err != nil {
+		logger.Fatalw(
+			"failed to unmarshal client groups",
+			"error", err,
+		)
+	}
+
+	groups := make([]void.Group, 0, len(groupCfgs))
+	for _, g := range groupCfgs {
+		groups = append(groups, void.Group{
+			Name:       g.Name,
+			Clients:    g.Clients,
+			Local:      g.Local.Records(ctx, logger, cacheDir),
+			Allow:      g.Allow.Records(ctx, logger, cacheDir),
+			Block:      g.Block.Records(ctx, logger, cacheDir),
+			Upstreams:  g.Upstream,
+			Mode:       g.Mode,
+			Schedules:  g.Schedules,
+			SafeSearch: g.SafeSearch,
+		})
+	}
+
+	var rateLimit void.RateLimitConfig
+	err = viper.UnmarshalKey("dns.ratelimit", &rateLimit)
+	if err != nil {
+		logger.Fatalw(
+			"failed to unmarshal rate limits",
+			"error", err,
+		)
+	}
+
+	var acl void.ACLConfig
+	err = viper.UnmarshalKey("dns.acl", &acl)
+	if err != nil {
+		logger.Fatalw(
+			"failed to unmarshal acl",
+			"error", err,
+		)
+	}
+
+	var zoneCfgs []zoneConfig
+	err = viper.UnmarshalKey("dns.zones", &zoneCfgs)
+	if err != nil {
+		logger.Fatalw(
+			"failed to unmarshal forwarded zones",
+			"error", err,
+		)
+	}
+
+	zones := make([]void.Zone, 0, len(zoneCfgs))
+	for _, z := range zoneCfgs {
+		zones = append(zones, void.Zone{
+			Domain:    z.Domain,
+			Upstreams: z.Upstream,
+		})
+	}
+
+	var safeSearchCfg safeSearchConfig
+	err = viper.UnmarshalKey("dns.safesearch", &safeSearchCfg)
+	if err != nil {
+		logger.Fatalw(
+			"failed to unmarshal safe search",
+			"error", err,
+		)
+	}
+
+	safeSearch := void.SafeSearchConfig{
+		Enabled: safeSearchCfg.Enabled,
+		Mapping: make(map[string]string, len(safeSearchCfg.Mapping)),
+	}
+
+	for _, m := range safeSearchCfg.Mapping {
+		safeSearch.Mapping[m.Domain] = m.Endpoint
+	}
+
+	var rebind *void.RebindConfig
+	if viper.IsSet("dns.rebind") {
+		rebind = &void.RebindConfig{}
+		err = viper.UnmarshalKey("dns.rebind", rebind)
+		if err != nil {
+			logger.Fatalw(
+				"failed to unmarshal rebind protection",
+				"error", err,
+			)
+		}
+	}
+
+	var pipeline []void.Category
+	for _, name := range viper.GetStringSlice("dns.pipeline") {
+		pipeline = append(pipeline, void.Category(name))
+	}
+
+	proxies, err := void.ParsePrefixes(viper.GetStringSlice("dns.proxy.trusted")...)
+	if err != nil {
+		logger.Fatalw(
+			"invalid trusted proxies",
+			"error", err,
+		)
+	}
+
+	// The certificate is shared by the tls, https, and quic listeners
+	var tlsConfig *tls.Config
+	certFile := viper.GetString("dns.tls.cert")
+	keyFile := viper.GetString("dns.tls.key")
+	if certFile != "" || keyFile != "" {
+		tlsConfig, err = void.ServerTLSConfig(certFile, keyFile)
+		if err != nil {
+			logger.Fatalw(
+				"failed to load tls certificate",
+				"cert", certFile,
+				"key", keyFile,
+				"error", err,
+			)
+		}
+	}
+
+	// Sockets passed by systemd socket activation replace the
+	// configured listen addresses
+	sockets, err := void.Activated()
+	if err != nil {
+		logger.Fatalw(
+			"failed to load activated sockets",
+			"error", err,
+		)
+	}
+
+	addrs, err := listenAddrs(tlsConfig != nil)
+	if err != nil {
+		logger.Fatalw(
+			"failed to parse listen addresses",
+			"error", err,
+		)
+	}
+
+	srv, err := void.NewServer(ctx, logger, void.Config{
+		Listen:  addrs,
+		Sockets: sockets,
+		TCP: &void.TCPConfig{
+			Timeout:     viper.GetDuration("dns.tcp.timeout"),
+			Connections: viper.GetInt("dns.tcp.connections"),
+			Proxies:     proxies,
+		},
+		TLS:          tlsConfig,
+		Upstreams:    viper.GetStringSlice("dns.upstream"),
+		Zones:        zones,
+		Pipeline:     pipeline,
+		Local:        localSrcs.Records(ctx, logger, cacheDir),
+		Allow:        allowSrcs.Records(ctx, logger, cacheDir),
+		Block:        blockSrcs.Records(ctx, logger, cacheDir),
+		PTRTTL:       viper.GetUint32("dns.ptr.ttl"),
+		BlockMode:    blockMode,
+		BlockModes:   blockModes,
+		Schedules:    schedules,
+		SafeSearch:   safeSearch,
+		Groups:       groups,
+		ACL:          acl,
+		RateLimit:    rateLimit,
+		Rebind:       rebind,
+		Control:      viper.GetString("dns.control.address"),
+		ControlToken: viper.GetString("dns.control.token"),
+		Metrics:      true,
+	})
+	if err != nil {
+		logger.Fatalw(
+			"failed to initialize server",
+			"error", err,
+		)
+	}
+
+	err = srv.Serve(ctx)
+	if err != nil {
+		logger.Errorw("failed to start server", "error", err)
+	}
+}
+
+// groupConfig is the configuration of a client group in dns.groups
+// where the lists are sources loaded the same as the global lists.
+type groupConfig struct {
+	Name       string
+	Clients    []string
+	Local      void.Sources
+	Allow      void.Sources
+	Block      void.Sources
+	Upstream   []string
+	Mode       *void.BlockMode
+	Schedules  void.Schedules
+	SafeSearch *bool
+}
+
+// zoneConfig is the configuration of a forwarded zone in dns.zones.
+type zoneConfig struct {
+	Domain   string
+	Upstream []string
+}
+
+// safeSearchConfig is the configuration of dns.safesearch, where the
+// mapping is a list as the domains cannot be configuration keys.
+type safeSearchConfig struct {
+	Enabled bool
+	Mapping []struct {
+		Domain   string
+		Endpoint string
+	}
+}
+
+// listenAddrs returns the addresses configured in dns.listen, or when
+// no addresses are configured, the addresses for all interfaces using
+// the configured ports.
+func listenAddrs(secure bool) ([]void.ListenAddr, error) {
+	listen := viper.GetStringSlice("dns.listen")
+	if len(listen) > 0 {
+		return void.ParseListen(listen...)
+	}
+
+	port := uint16(viper.GetUint("dns.port"))
+	addrs := []void.ListenAddr{
+		{Proto: void.UDP, Port: port},
+		{Proto: void.TCP, Port: port},
+	}
+
+	// DNS-over-TLS is only served when a certificate is configured
+	if secure {
+		addrs = append(addrs, void.ListenAddr{
+			Proto: void.TLS,
+			Port:  uint16(viper.GetUint("dns.tls.port")),
+		})
+	}
+
+	if viper.GetBool("dns.https.enabled") {
+		addrs = append(addrs, void.ListenAddr{
+			Proto: void.HTTPS,
+			Port:  uint16(viper.GetUint("dns.https.port")),
+		})
+	}
+
+	if viper.GetBool("dns.quic.enabled") {
+		addrs = append(addrs, void.ListenAddr{
+			Proto: void.QUIC,
+			Port:  uint16(viper.GetUint("dns.quic.port")),
+		})
+	}
+
+	return addrs, nil
+}
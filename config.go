@@ -1,4 +1,6 @@
-package main
+package void
+
+import "strings"
 
 // Type indicates the type of a record to ensure proper analysis.
 type Type string
@@ -15,8 +17,32 @@ const (
 	// REGEX indicates a regular expression to match DNS requests
 	// against for blocking many records with a single filter.
 	REGEX Type = "regex"
+
+	// RPZ indicates a Response Policy Zone file where each trigger is
+	// converted to a DIRECT or WILDCARD record with the action of the
+	// policy.
+	RPZ Type = "rpz"
+
+	// CIDR indicates a list of networks, one CIDR or IP per line, where
+	// the A and AAAA answers of upstream responses within the networks
+	// are blocked.
+	CIDR Type = "cidr"
 )
 
 func (t Type) String() string {
 	return string(t)
 }
+
+// categories merges the per category configurations with lower cased
+// categories, as the configuration keys are lower cased, where the later
+// maps replace the configuration of the same category.
+func categories[T any](maps ...map[string]T) map[string]T {
+	merged := make(map[string]T)
+	for _, m := range maps {
+		for category, v := range m {
+			merged[strings.ToLower(category)] = v
+		}
+	}
+
+	return merged
+}
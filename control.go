@@ -0,0 +1,209 @@
+package void
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	// controlPause is the path for listing (GET) and creating (POST)
+	// pauses of blocking.
+	controlPause = "/pause"
+
+	// controlResume is the path for resuming (POST) blocking.
+	controlResume = "/resume"
+
+	// controlTimeout bounds the reading of control requests.
+	controlTimeout = time.Second * 10
+)
+
+// ControlHandler returns an http.Handler for controlling the server at
+// runtime, where each request must be authorized with the bearer token.
+//
+//	POST /pause?duration=5m[&group=kids]  pauses blocking
+//	POST /resume[?group=kids]             resumes blocking
+//	GET  /pause                           lists the active pauses
+//
+// Requests without a group apply to every client.
+func ControlHandler(
+	ctx context.Context,
+	logger Logger,
+	pauses *Pauses,
+	token string,
+) (http.Handler, error) {
+	err := checkNil(ctx, logger, pauses)
+	if err != nil {
+		return nil, err
+	}
+
+	if token == "" {
+		return nil, errors.New("control interface requires a token")
+	}
+
+	c := &control{ctx, logger, pauses, []byte("Bearer " + token)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(controlPause, c.pause)
+	mux.HandleFunc(controlResume, c.resume)
+
+	return c.authorize(mux), nil
+}
+
+type control struct {
+	ctx    context.Context
+	logger Logger
+	pauses *Pauses
+
+	// authorization is the expected Authorization header of requests
+	authorization []byte
+}
+
+// authorize rejects the requests without the bearer token, which also
+// prevents browsers from sending cross-origin requests to the handler.
+func (c *control) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(auth, c.authorization) != 1 {
+			c.logger.Warnw(
+				"unauthorized control request",
+				"category", PAUSE,
+				"remote", r.RemoteAddr,
+				"path", r.URL.Path,
+			)
+
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (c *control) pause(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		c.json(w, http.StatusOK, c.pauses.Active())
+		return
+	case http.MethodPost:
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	d, err := time.ParseDuration(r.FormValue("duration"))
+	if err != nil {
+		http.Error(w, "invalid duration", http.StatusBadRequest)
+		return
+	}
+
+	e, err := c.pauses.Pause(r.FormValue("group"), d)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.json(w, http.StatusOK, e)
+}
+
+func (c *control) resume(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !c.pauses.Resume(r.FormValue("group")) {
+		http.Error(w, "not paused", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *control) json(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		c.logger.Errorw(
+			"failed to write control response",
+			"category", PAUSE,
+			"error", err,
+		)
+	}
+}
+
+// ControlListener creates a plain HTTP listener for the control handler,
+// which should only be bound to a trusted address such as localhost as the
+// token is not encrypted.
+func ControlListener(
+	logger Logger,
+	addr string,
+	handler http.Handler,
+) (Listener, error) {
+	err := checkNil(logger, handler)
+	if err != nil {
+		return nil, err
+	}
+
+	return &controlListener{
+		addr:    addr,
+		handler: handler,
+		logger:  logger,
+	}, nil
+}
+
+type controlListener struct {
+	addr    string
+	handler http.Handler
+	logger  Logger
+}
+
+func (l *controlListener) String() string {
+	return fmt.Sprintf("http://%s", l.addr)
+}
+
+func (l *controlListener) Serve(ctx context.Context) error {
+	ln, err := net.Listen(network(TCP, l.addr), l.addr)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		Handler:           l.handler,
+		ReadHeaderTimeout: controlTimeout,
+		ReadTimeout:       controlTimeout,
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			err := srv.Shutdown(context.Background())
+			if err != nil {
+				l.logger.Errorw(
+					"failed to gracefully shutdown server",
+					"net", "control",
+					"error", err,
+				)
+			}
+		}
+	}()
+
+	err = srv.Serve(ln)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
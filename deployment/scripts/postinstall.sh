@@ -15,11 +15,11 @@ configure()
 		systemctl restart systemd-resolved
 	fi
 	
-	systemctl enable void.service
+	systemctl enable void.socket void.service
 	
 	systemctl daemon-reload
 	
-	systemctl start void.service
+	systemctl start void.socket void.service
 }
 
 case $1 in
@@ -2,11 +2,11 @@
 set -e
 
 stop_services() {
-    systemctl stop void.service
+    systemctl stop void.service void.socket
 }
 
 disable_services() {
-    systemctl disable void.service
+    systemctl disable void.service void.socket
 
     systemctl daemon-reload
 }
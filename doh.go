@@ -0,0 +1,344 @@
+package void
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// dohPath is the URI path for DNS-over-HTTPS requests.
+	dohPath = "/dns-query"
+
+	// dohContentType is the media type of DNS messages in DNS-over-HTTPS
+	// requests and responses as defined by RFC 8484.
+	dohContentType = "application/dns-message"
+
+	// dohTimeout is the maximum time spent waiting for the pipeline to
+	// answer a DNS-over-HTTPS request.
+	dohTimeout = time.Second * 5
+)
+
+// DoHHandler returns an http.Handler which serves DNS-over-HTTPS
+// (RFC 8484) requests on the /dns-query path. Both GET and POST requests
+// are decoded into DNS messages and passed to the DNS handler so that
+// they are evaluated in the same pipeline as all other requests.
+func DoHHandler(
+	ctx context.Context,
+	logger Logger,
+	handler dns.Handler,
+) (http.Handler, error) {
+	err := checkNil(ctx, logger, handler)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(dohPath, &doh{ctx, logger, edns(handler, false)})
+
+	return mux, nil
+}
+
+type doh struct {
+	ctx     context.Context
+	logger  Logger
+	handler dns.Handler
+}
+
+func (d *doh) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req, status, err := d.read(r)
+	if err != nil {
+		d.logger.Debugw(
+			"invalid request",
+			"category", HTTPS,
+			"client", r.RemoteAddr,
+			"error", err,
+		)
+
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	writer := &httpWriter{
+		local:  addrFromContext(r.Context()),
+		remote: tcpAddr(r.RemoteAddr),
+		res:    make(chan *dns.Msg, 1),
+		closed: make(chan struct{}),
+	}
+
+	d.handler.ServeDNS(writer, req)
+
+	ctx, cancel := context.WithTimeout(r.Context(), dohTimeout)
+	defer cancel()
+
+	select {
+	case <-d.ctx.Done():
+		http.Error(w, "server shutting down", http.StatusServiceUnavailable)
+	case <-writer.closed:
+		http.Error(w, "request closed", http.StatusServiceUnavailable)
+	case <-ctx.Done():
+		http.Error(w, "request timed out", http.StatusGatewayTimeout)
+	case res := <-writer.res:
+		data, err := res.Pack()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", dohContentType)
+		w.Header().Set(
+			"Cache-Control",
+			fmt.Sprintf("max-age=%d", minTTL(res)),
+		)
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(http.StatusOK)
+
+		_, err = w.Write(data)
+		if err != nil {
+			d.logger.Errorw(
+				"failed to write response",
+				"category", HTTPS,
+				"client", r.RemoteAddr,
+				"error", err,
+			)
+		}
+	}
+}
+
+// read decodes the DNS message from the http request returning
+// the http status to respond with if the request is invalid.
+func (d *doh) read(r *http.Request) (*dns.Msg, int, error) {
+	var data []byte
+	switch r.Method {
+	case http.MethodGet:
+		param := r.URL.Query().Get("dns")
+		if param == "" {
+			return nil, http.StatusBadRequest, errors.New("missing dns parameter")
+		}
+
+		var err error
+		data, err = base64.RawURLEncoding.DecodeString(
+			strings.TrimRight(param, "="),
+		)
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+
+	case http.MethodPost:
+		ct := r.Header.Get("Content-Type")
+		if ct != dohContentType {
+			return nil,
+				http.StatusUnsupportedMediaType,
+				fmt.Errorf("unsupported content type [%s]", ct)
+		}
+
+		var err error
+		data, err = io.ReadAll(io.LimitReader(r.Body, dns.MaxMsgSize))
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+
+	default:
+		return nil,
+			http.StatusMethodNotAllowed,
+			fmt.Errorf("unsupported method [%s]", r.Method)
+	}
+
+	req := &dns.Msg{}
+	err := req.Unpack(data)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	return req, http.StatusOK, nil
+}
+
+// minTTL returns the lowest TTL of the records in the response for use
+// as the freshness lifetime of the http response.
+func minTTL(res *dns.Msg) uint32 {
+	var ttl uint32
+	found := false
+	for _, section := range [][]dns.RR{res.Answer, res.Ns, res.Extra} {
+		for _, rr := range section {
+			if rr.Header().Rrtype == dns.TypeOPT {
+				continue
+			}
+
+			if !found || rr.Header().Ttl < ttl {
+				ttl = rr.Header().Ttl
+				found = true
+			}
+		}
+	}
+
+	return ttl
+}
+
+func addrFromContext(ctx context.Context) net.Addr {
+	addr, ok := ctx.Value(http.LocalAddrContextKey).(net.Addr)
+	if !ok {
+		return &net.TCPAddr{}
+	}
+
+	return addr
+}
+
+func tcpAddr(addr string) net.Addr {
+	ap, err := netip.ParseAddrPort(addr)
+	if err != nil {
+		return &net.TCPAddr{}
+	}
+
+	return net.TCPAddrFromAddrPort(ap)
+}
+
+// httpWriter implements the dns.ResponseWriter for DNS-over-HTTPS
+// requests, passing the response back to the waiting http handler.
+type httpWriter struct {
+	local  net.Addr
+	remote net.Addr
+	res    chan *dns.Msg
+	closed chan struct{}
+	once   sync.Once
+}
+
+var _ dns.ResponseWriter = (*httpWriter)(nil)
+
+func (h *httpWriter) LocalAddr() net.Addr  { return h.local }
+func (h *httpWriter) RemoteAddr() net.Addr { return h.remote }
+
+func (h *httpWriter) WriteMsg(res *dns.Msg) error {
+	select {
+	case h.res <- res:
+		return nil
+	default:
+		return errors.New("response already written")
+	}
+}
+
+func (h *httpWriter) Write(data []byte) (int, error) {
+	res := &dns.Msg{}
+	err := res.Unpack(data)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(data), h.WriteMsg(res)
+}
+
+func (h *httpWriter) Close() error {
+	h.once.Do(func() { close(h.closed) })
+
+	return nil
+}
+
+func (h *httpWriter) TsigStatus() error     { return nil }
+func (h *httpWriter) TsigTimersOnly(_ bool) {}
+func (h *httpWriter) Hijack()               {}
+
+// DoHListener creates a DNS-over-HTTPS listener at the provided address
+// serving HTTP/2 (and HTTP/1.1) over TLS.
+func DoHListener(
+	ctx context.Context,
+	logger Logger,
+	addr string,
+	handler dns.Handler,
+	tcp *TCPConfig,
+	tlsConfig *tls.Config,
+) (Listener, error) {
+	if tlsConfig == nil {
+		return nil, fmt.Errorf("missing tls configuration for [%s]", addr)
+	}
+
+	h, err := DoHHandler(ctx, logger, handler)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dohListener{
+		addr:    addr,
+		handler: h,
+		tcp:     tcp,
+		tls:     tlsConfig,
+		logger:  logger,
+	}, nil
+}
+
+type dohListener struct {
+	addr    string
+	handler http.Handler
+	tcp     *TCPConfig
+	tls     *tls.Config
+	logger  Logger
+	socket  Socket
+}
+
+func (l *dohListener) inherit(s Socket) error {
+	if s.Listener == nil {
+		return fmt.Errorf("socket type mismatch for [%s]", s)
+	}
+
+	l.socket = s
+	return nil
+}
+
+func (l *dohListener) String() string {
+	return fmt.Sprintf("%s://%s", HTTPS, l.addr)
+}
+
+func (l *dohListener) Serve(ctx context.Context) error {
+	ln := l.socket.Listener
+	if ln == nil {
+		var err error
+		ln, err = net.Listen(network(TCP, l.addr), l.addr)
+		if err != nil {
+			return err
+		}
+	}
+
+	srv := &http.Server{
+		Handler:           l.handler,
+		TLSConfig:         l.tls.Clone(),
+		IdleTimeout:       l.tcp.timeout(),
+		ReadHeaderTimeout: l.tcp.timeout(),
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			err := srv.Shutdown(context.Background())
+			if err != nil {
+				l.logger.Errorw(
+					"failed to gracefully shutdown server",
+					"net", HTTPS,
+					"error", err,
+				)
+			}
+		}
+	}()
+
+	// The certificates are provided by the tls configuration and
+	// HTTP/2 is negotiated automatically by ServeTLS
+	err := srv.ServeTLS(l.tcp.proxy(l.tcp.limit(ln)), "", "")
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,223 @@
+package void
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func Test_DoHHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logger := &NOOPLogger{}
+	handler, requests := Convert(ctx, logger, false)
+	go answer(ctx, requests)
+
+	h, err := DoHHandler(ctx, logger, dns.HandlerFunc(handler))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewUnstartedServer(h)
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	query, err := Question(t, "test.example.tld.", dns.TypeA).Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(query)
+
+	tests := map[string]struct {
+		method      string
+		path        string
+		contentType string
+		body        []byte
+		status      int
+	}{
+		"get": {
+			method: http.MethodGet,
+			path:   dohPath + "?dns=" + encoded,
+			status: http.StatusOK,
+		},
+		"get-padded": {
+			method: http.MethodGet,
+			path:   dohPath + "?dns=" + base64.URLEncoding.EncodeToString(query),
+			status: http.StatusOK,
+		},
+		"post": {
+			method:      http.MethodPost,
+			path:        dohPath,
+			contentType: dohContentType,
+			body:        query,
+			status:      http.StatusOK,
+		},
+		"get-missing-param": {
+			method: http.MethodGet,
+			path:   dohPath,
+			status: http.StatusBadRequest,
+		},
+		"get-invalid-base64": {
+			method: http.MethodGet,
+			path:   dohPath + "?dns=***",
+			status: http.StatusBadRequest,
+		},
+		"get-invalid-message": {
+			method: http.MethodGet,
+			path:   dohPath + "?dns=AAAA",
+			status: http.StatusBadRequest,
+		},
+		"post-invalid-content-type": {
+			method:      http.MethodPost,
+			path:        dohPath,
+			contentType: "text/plain",
+			body:        query,
+			status:      http.StatusUnsupportedMediaType,
+		},
+		"put": {
+			method: http.MethodPut,
+			path:   dohPath,
+			status: http.StatusMethodNotAllowed,
+		},
+		"not-found": {
+			method: http.MethodGet,
+			path:   "/resolve?dns=" + encoded,
+			status: http.StatusNotFound,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(
+				ctx,
+				test.method,
+				srv.URL+test.path,
+				bytes.NewReader(test.body),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if test.contentType != "" {
+				req.Header.Set("Content-Type", test.contentType)
+			}
+
+			resp, err := srv.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != test.status {
+				t.Fatalf("expected status %d, got %d", test.status, resp.StatusCode)
+			}
+
+			if test.status != http.StatusOK {
+				return
+			}
+
+			if resp.ProtoMajor != 2 {
+				t.Fatalf("expected HTTP/2, got %s", resp.Proto)
+			}
+
+			if ct := resp.Header.Get("Content-Type"); ct != dohContentType {
+				t.Fatalf("expected content type %s, got %s", dohContentType, ct)
+			}
+
+			data, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res := &dns.Msg{}
+			err = res.Unpack(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(res.Answer) != 1 {
+				t.Fatalf("expected 1 answer, got %d", len(res.Answer))
+			}
+
+			if cc := resp.Header.Get("Cache-Control"); cc != "max-age=3600" {
+				t.Fatalf("expected max-age=3600, got %s", cc)
+			}
+		})
+	}
+}
+
+func Test_DoHListener(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logger := &NOOPLogger{}
+	handler, requests := Convert(ctx, logger, false)
+	go answer(ctx, requests)
+
+	certFile, keyFile, pool := selfSigned(t)
+	tlsConfig, err := ServerTLSConfig(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := freePort(t)
+	l, err := DoHListener(ctx, logger, addr, dns.HandlerFunc(handler), nil, tlsConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := serveTest(t, ctx, l)
+	defer stop()
+
+	query, err := Question(t, "test.example.tld.", dns.TypeA).Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		"https://"+addr+dohPath,
+		bytes.NewReader(query),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", dohContentType)
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			ForceAttemptHTTP2: true,
+			TLSClientConfig: &tls.Config{
+				MinVersion: tls.VersionTLS12,
+				RootCAs:    pool,
+				ServerName: "localhost",
+			},
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	if resp.ProtoMajor != 2 {
+		t.Fatalf("expected HTTP/2, got %s", resp.Proto)
+	}
+}
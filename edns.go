@@ -0,0 +1,93 @@
+package void
+
+import (
+	"github.com/miekg/dns"
+)
+
+// ednsUDPSize is the UDP payload size advertised to clients and the upper
+// bound of UDP responses regardless of the size advertised by the client.
+// The value avoids IP fragmentation on common networks (DNS Flag Day 2020).
+const ednsUDPSize = 1232
+
+// edns wraps the handler so that the responses echo the EDNS0 OPT record
+// of the request (RFC 6891). For UDP the responses are also truncated to
+// fit the payload size of the client, setting the TC bit so the client
+// retries over TCP.
+func edns(next dns.Handler, udp bool) dns.Handler {
+	return dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		size := 0
+		if udp {
+			size = udpSize(req)
+		}
+
+		next.ServeDNS(&ednsWriter{w, req, size}, req)
+	})
+}
+
+// udpSize returns the maximum size of a UDP response to the request,
+// which is 512 bytes for clients which do not support EDNS0.
+func udpSize(req *dns.Msg) int {
+	opt := req.IsEdns0()
+	if opt == nil {
+		return dns.MinMsgSize
+	}
+
+	size := int(opt.UDPSize())
+	switch {
+	case size < dns.MinMsgSize:
+		return dns.MinMsgSize
+	case size > ednsUDPSize:
+		return ednsUDPSize
+	default:
+		return size
+	}
+}
+
+// truncates indicates if the writer truncates responses to the size of
+// a datagram, which is only the case for UDP clients.
+func truncates(w dns.ResponseWriter) bool {
+	e, ok := w.(*ednsWriter)
+	return ok && e.size > 0
+}
+
+// ednsWriter fits the response to the request before writing, where a
+// size of zero disables truncation for stream transports.
+type ednsWriter struct {
+	dns.ResponseWriter
+	req  *dns.Msg
+	size int
+}
+
+func (e *ednsWriter) WriteMsg(res *dns.Msg) error {
+	// Copy the response since it may be shared with the cache
+	res = res.Copy()
+	echoEDNS(e.req, res)
+
+	if e.size > 0 {
+		res.Truncate(e.size)
+	}
+
+	return e.ResponseWriter.WriteMsg(res)
+}
+
+// echoEDNS replaces the OPT record of the response with one matching the
+// request. Responses to requests without an OPT record must not include
+// one, and options from upstream (or cached) responses are not forwarded
+// to the client. Extended rcodes are carried by the Rcode of the message
+// and are restored into the new OPT record when the message is packed.
+func echoEDNS(req, res *dns.Msg) {
+	extra := res.Extra[:0:0]
+	for _, rr := range res.Extra {
+		if rr.Header().Rrtype != dns.TypeOPT {
+			extra = append(extra, rr)
+		}
+	}
+	res.Extra = extra
+
+	opt := req.IsEdns0()
+	if opt == nil {
+		return
+	}
+
+	res.SetEdns0(ednsUDPSize, opt.Do())
+}
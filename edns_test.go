@@ -0,0 +1,225 @@
+package void
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+type captureWriter struct {
+	dns.ResponseWriter
+	response *dns.Msg
+}
+
+func (c *captureWriter) WriteMsg(res *dns.Msg) error {
+	c.response = res
+	return nil
+}
+
+func txtResponse(req *dns.Msg, records int) *dns.Msg {
+	res := (&dns.Msg{}).SetReply(req)
+	for i := 0; i < records; i++ {
+		res.Answer = append(res.Answer, &dns.TXT{
+			Hdr: dns.RR_Header{
+				Name:   req.Question[0].Name,
+				Rrtype: dns.TypeTXT,
+				Class:  dns.ClassINET,
+				Ttl:    DEFAULTTTL,
+			},
+			Txt: []string{fmt.Sprintf("record-%03d-%0100d", i, 0)},
+		})
+	}
+
+	return res
+}
+
+func Test_EDNS(t *testing.T) {
+	tests := map[string]struct {
+		udpSize   uint16 // zero disables EDNS0 on the request
+		do        bool
+		udp       bool
+		records   int
+		upstream  bool // the response includes an upstream OPT record
+		truncated bool
+		maxSize   int
+	}{
+		"udp-no-edns-small": {
+			udp:     true,
+			records: 1,
+			maxSize: dns.MinMsgSize,
+		},
+		"udp-no-edns-large": {
+			udp:       true,
+			records:   20,
+			truncated: true,
+			maxSize:   dns.MinMsgSize,
+		},
+		"udp-no-edns-upstream-opt": {
+			udp:      true,
+			records:  1,
+			upstream: true,
+			maxSize:  dns.MinMsgSize,
+		},
+		"udp-edns-fits": {
+			udpSize: 4096,
+			udp:     true,
+			records: 8,
+			maxSize: ednsUDPSize,
+		},
+		"udp-edns-capped": {
+			udpSize:   4096,
+			udp:       true,
+			records:   20,
+			truncated: true,
+			maxSize:   ednsUDPSize,
+		},
+		"udp-edns-small-buffer": {
+			udpSize:   600,
+			do:        true,
+			udp:       true,
+			records:   8,
+			truncated: true,
+			maxSize:   600,
+		},
+		"udp-edns-below-minimum": {
+			udpSize:   100,
+			udp:       true,
+			records:   8,
+			truncated: true,
+			maxSize:   dns.MinMsgSize,
+		},
+		"tcp-no-truncation": {
+			records: 20,
+			maxSize: dns.MaxMsgSize,
+		},
+		"tcp-edns-upstream-opt": {
+			udpSize:  4096,
+			records:  1,
+			upstream: true,
+			maxSize:  dns.MaxMsgSize,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := Question(t, "test.example.tld.", dns.TypeTXT)
+			if test.udpSize > 0 {
+				req.SetEdns0(test.udpSize, test.do)
+			}
+
+			res := txtResponse(req, test.records)
+			if test.upstream {
+				res.SetEdns0(dns.DefaultMsgSize, true)
+				res.IsEdns0().Option = append(
+					res.IsEdns0().Option,
+					&dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: "0102030405060708"},
+				)
+			}
+
+			w := &captureWriter{}
+			edns(dns.HandlerFunc(func(w dns.ResponseWriter, _ *dns.Msg) {
+				err := w.WriteMsg(res)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}), test.udp).ServeDNS(w, req)
+
+			if w.response == nil {
+				t.Fatal("expected response")
+			}
+
+			if w.response == res {
+				t.Fatal("expected response to be copied")
+			}
+
+			if len(res.Answer) != test.records {
+				t.Fatal("original response was modified")
+			}
+
+			if w.response.Truncated != test.truncated {
+				t.Fatalf(
+					"expected truncated %v, got %v",
+					test.truncated,
+					w.response.Truncated,
+				)
+			}
+
+			if w.response.Len() > test.maxSize {
+				t.Fatalf(
+					"expected size <= %d, got %d",
+					test.maxSize,
+					w.response.Len(),
+				)
+			}
+
+			opt := w.response.IsEdns0()
+			if test.udpSize == 0 {
+				if opt != nil {
+					t.Fatal("expected no OPT record in response")
+				}
+
+				return
+			}
+
+			if opt == nil {
+				t.Fatal("expected OPT record in response")
+			}
+
+			if opt.UDPSize() != ednsUDPSize {
+				t.Fatalf("expected udp size %d, got %d", ednsUDPSize, opt.UDPSize())
+			}
+
+			if opt.Do() != test.do {
+				t.Fatalf("expected DO %v, got %v", test.do, opt.Do())
+			}
+
+			if len(opt.Option) != 0 {
+				t.Fatalf("expected no options, got %v", opt.Option)
+			}
+		})
+	}
+}
+
+func Test_Listener_UDP_Truncation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	addr := freePort(t)
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		_ = w.WriteMsg(txtResponse(req, 20))
+	})
+
+	logger := &NOOPLogger{}
+	listeners := []Listener{}
+	for _, proto := range []Protocol{UDP, TCP} {
+		l, err := DNSListener(logger, proto, addr, handler, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		listeners = append(listeners, l)
+	}
+
+	defer serveTest(t, ctx, listeners...)()
+
+	req := Question(t, "test.example.tld.", dns.TypeTXT)
+	res, _, err := (&dns.Client{Net: string(UDP)}).Exchange(req, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !res.Truncated {
+		t.Fatal("expected truncated udp response")
+	}
+
+	res, _, err = (&dns.Client{Net: string(TCP)}).Exchange(req, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Truncated || len(res.Answer) != 20 {
+		t.Fatalf("expected 20 answers over tcp, got %d", len(res.Answer))
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package void
 
 import (
 	"fmt"
@@ -19,11 +19,19 @@ func checkNil(values ...any) error {
 type Category string
 
 const (
-	LOCAL    Category = "local"
-	ALLOW    Category = "allow"
-	BLOCK    Category = "block"
-	CACHE    Category = "cache"
-	UPSTREAM Category = "upstream"
+	LOCAL      Category = "local"
+	ALLOW      Category = "allow"
+	BLOCK      Category = "block"
+	CACHE      Category = "cache"
+	UPSTREAM   Category = "upstream"
+	VALIDATE   Category = "validate"
+	RATELIMIT  Category = "ratelimit"
+	ACCESS     Category = "access"
+	GROUP      Category = "group"
+	PAUSE      Category = "pause"
+	REBIND     Category = "rebind"
+	SAFESEARCH Category = "safesearch"
+	FORWARD    Category = "forward"
 )
 
 func (c Category) String() string {
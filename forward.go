@@ -0,0 +1,143 @@
+package void
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// Zone forwards the requests for a domain to the upstreams of the zone
+// rather than the global or client group upstreams.
+type Zone struct {
+	// Domain matches the domain and each of its subdomains (e.g.
+	// corp.example or 10.in-addr.arpa), where the most specific zone of a
+	// request is used. A leading *. is accepted and is the same zone as
+	// the domain.
+	Domain string
+
+	// Upstreams are the addresses of the upstream DNS servers of the zone
+	// in the format <proto>://<ip>[:<port>].
+	Upstreams []string
+}
+
+// domain returns the validated domain of the zone.
+func (z Zone) domain() (string, error) {
+	domain := strings.ToLower(strings.TrimSuffix(z.Domain, "."))
+	if domain == "" {
+		return "", fmt.Errorf("zone missing domain")
+	}
+
+	if len(z.Upstreams) == 0 {
+		return "", fmt.Errorf("zone [%s] missing upstreams", domain)
+	}
+
+	if strings.Contains(domain, "*") {
+		_, err := Wildcard(domain)
+		if err != nil || !strings.HasPrefix(domain, "*.") {
+			return "", fmt.Errorf("zone [%s]: %w", domain, ErrWildcard)
+		}
+
+		domain = strings.TrimPrefix(domain, "*.")
+	}
+
+	if _, ok := dns.IsDomainName(domain); !ok || domain == "" {
+		return "", fmt.Errorf("zone [%s]: %w", z.Domain, ErrDomain)
+	}
+
+	return domain, nil
+}
+
+// records returns the records matching the domain of the zone and each
+// of its subdomains.
+func (z Zone) records() ([]*Record, error) {
+	domain, err := z.domain()
+	if err != nil {
+		return nil, err
+	}
+
+	return []*Record{
+		{
+			Pattern:  domain,
+			Type:     DIRECT,
+			Category: string(FORWARD),
+		},
+		{
+			Pattern:  "*." + domain,
+			Type:     WILDCARD,
+			Category: string(FORWARD),
+		},
+	}, nil
+}
+
+// Forwarder creates the conditional forwarding of requests within the
+// zone domains to the upstreams of their zones.
+func Forwarder(
+	ctx context.Context,
+	logger Logger,
+	zones map[string]chan<- *Request,
+) (*Forward, error) {
+	err := checkNil(ctx, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Forward{
+		ctx:    ctx,
+		logger: logger,
+		zones:  zones,
+	}, nil
+}
+
+// Forward sends the requests of the zones to their upstreams, where the
+// requests outside of the zones continue to the next upstream.
+type Forward struct {
+	ctx    context.Context
+	logger Logger
+	zones  map[string]chan<- *Request
+}
+
+func (f *Forward) Intercept(
+	ctx context.Context,
+	req *Request,
+) (*Request, bool) {
+	zone, up := f.zone(strings.ToLower(req.Record()))
+	if up == nil {
+		return req, true
+	}
+
+	select {
+	case <-f.ctx.Done():
+	case <-ctx.Done():
+	case up <- req:
+		f.logger.Debugw(
+			"forwarded",
+			"category", FORWARD,
+			"name", req.r.Question[0].Name,
+			"type", dns.Type(req.r.Question[0].Qtype),
+			"zone", zone,
+		)
+	}
+
+	return nil, false
+}
+
+// zone returns the most specific zone containing the name, removing the
+// labels of the name from the left until it matches a zone domain.
+func (f *Forward) zone(name string) (string, chan<- *Request) {
+	for name != "" {
+		if up, ok := f.zones[name]; ok {
+			return name, up
+		}
+
+		i := strings.Index(name, ".")
+		if i == -1 {
+			break
+		}
+
+		name = name[i+1:]
+	}
+
+	return "", nil
+}
@@ -0,0 +1,169 @@
+package void
+
+import (
+	"context"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func Test_Forward_Intercept(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	corp := make(chan *Request, 1)
+	lab := make(chan *Request, 1)
+	router := make(chan *Request, 1)
+
+	zones := make(map[string]chan<- *Request)
+	for domain, up := range map[string]chan *Request{
+		"corp.example":        corp,
+		"*.lab.corp.example":  lab,
+		"10.in-addr.arpa.":    router,
+		"*.2.10.in-addr.arpa": lab,
+	} {
+		d, err := Zone{Domain: domain, Upstreams: []string{"127.0.0.1"}}.domain()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		zones[d] = up
+	}
+
+	f, err := Forwarder(ctx, &NOOPLogger{}, zones)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		name  string
+		qtype uint16
+		zone  chan *Request
+	}{
+		"subdomain": {
+			name:  "git.corp.example.",
+			qtype: dns.TypeA,
+			zone:  corp,
+		},
+		"apex": {
+			name:  "Corp.Example.",
+			qtype: dns.TypeSOA,
+			zone:  corp,
+		},
+		"nested": {
+			name:  "host.lab.corp.example.",
+			qtype: dns.TypeA,
+			zone:  lab,
+		},
+		"nested-apex": {
+			name:  "lab.corp.example.",
+			qtype: dns.TypeA,
+			zone:  lab,
+		},
+		"nested-sibling": {
+			name:  "host.dev.corp.example.",
+			qtype: dns.TypeA,
+			zone:  corp,
+		},
+		"reverse": {
+			name:  "2.0.0.10.in-addr.arpa.",
+			qtype: dns.TypePTR,
+			zone:  router,
+		},
+		"nested-reverse": {
+			name:  "1.0.2.10.in-addr.arpa.",
+			qtype: dns.TypePTR,
+			zone:  lab,
+		},
+		"global": {
+			name:  "example.tld.",
+			qtype: dns.TypeA,
+		},
+		"suffix": {
+			name:  "notcorp.example.",
+			qtype: dns.TypeA,
+		},
+		"other-reverse": {
+			name:  "1.0.168.192.in-addr.arpa.",
+			qtype: dns.TypePTR,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := &Request{r: Question(t, test.name, test.qtype)}
+
+			_, pass := f.Intercept(ctx, req)
+			if pass != (test.zone == nil) {
+				t.Fatalf("expected pass %v, got %v", test.zone == nil, pass)
+			}
+
+			if test.zone == nil {
+				return
+			}
+
+			select {
+			case forwarded := <-test.zone:
+				if forwarded != req {
+					t.Fatal("expected request to be forwarded to the zone")
+				}
+			default:
+				t.Fatal("expected request in zone upstream")
+			}
+		})
+	}
+}
+
+func Test_Zone_Domain(t *testing.T) {
+	tests := map[string]struct {
+		zone     Zone
+		expected string
+		err      bool
+	}{
+		"domain": {
+			zone:     Zone{Domain: "Corp.Example.", Upstreams: []string{"127.0.0.1"}},
+			expected: "corp.example",
+		},
+		"wildcard": {
+			zone:     Zone{Domain: "*.corp.example", Upstreams: []string{"127.0.0.1"}},
+			expected: "corp.example",
+		},
+		"missing-domain": {
+			zone: Zone{Upstreams: []string{"127.0.0.1"}},
+			err:  true,
+		},
+		"missing-upstreams": {
+			zone: Zone{Domain: "corp.example"},
+			err:  true,
+		},
+		"partial-label": {
+			zone: Zone{Domain: "*corp.example", Upstreams: []string{"127.0.0.1"}},
+			err:  true,
+		},
+		"nested-wildcard": {
+			zone: Zone{Domain: "*.*.corp.example", Upstreams: []string{"127.0.0.1"}},
+			err:  true,
+		},
+		"inner-wildcard": {
+			zone: Zone{Domain: "lab.*.example", Upstreams: []string{"127.0.0.1"}},
+			err:  true,
+		},
+		"root": {
+			zone: Zone{Domain: "*.", Upstreams: []string{"127.0.0.1"}},
+			err:  true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			domain, err := test.zone.domain()
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+
+			if domain != test.expected {
+				t.Fatalf("expected domain %s, got %s", test.expected, domain)
+			}
+		})
+	}
+}
@@ -0,0 +1,322 @@
+package void
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// arpTable is the ipv4 neighbor table used to find the MAC
+	// address of clients.
+	arpTable = "/proc/net/arp"
+
+	// arpRefresh is how often the neighbor table is read.
+	arpRefresh = time.Minute
+)
+
+// Group is a set of clients, identified by CIDR (or IP), MAC address, or
+// the name of a local record, which are evaluated with the lists and
+// upstreams of the group rather than the global configuration.
+//
+// The lists of a group replace the global lists so that a group without
+// lists neither resolves local records nor blocks, while a group without
+// upstreams uses the global upstreams.
+type Group struct {
+	Name      string
+	Clients   []string
+	Local     []*Record
+	Allow     []*Record
+	Block     []*Record
+	Upstreams []string
+
+	// Mode is the default block mode of the group, defaulting to the
+	// global block mode.
+	Mode *BlockMode
+
+	// Schedules replace the global schedules of the same categories.
+	Schedules Schedules
+
+	// SafeSearch enables or disables safe search for the group,
+	// defaulting to the global setting.
+	SafeSearch *bool
+}
+
+// ClientGroups creates the group selection stage, where the names of the
+// group clients are resolved using the local records.
+func ClientGroups(
+	ctx context.Context,
+	logger Logger,
+	local []*Record,
+	groups ...Group,
+) (*Groups, error) {
+	err := checkNil(ctx, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &Groups{
+		ctx:    ctx,
+		logger: logger,
+		groups: make([]*clientGroup, 0, len(groups)),
+	}
+
+	seen := make(map[string]bool, len(groups))
+	for _, group := range groups {
+		if group.Name == "" {
+			return nil, fmt.Errorf("client group missing name")
+		}
+
+		if seen[group.Name] {
+			return nil, fmt.Errorf("duplicate client group [%s]", group.Name)
+		}
+		seen[group.Name] = true
+
+		cg, err := newClientGroup(group, local)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(cg.macs) > 0 && g.neighbors == nil {
+			g.neighbors = &neighbors{path: arpTable, refresh: arpRefresh}
+		}
+
+		g.groups = append(g.groups, cg)
+	}
+
+	return g, nil
+}
+
+// Groups selects the group of the client of each request, which then
+// determines the stages used for the local, allow, and block lists.
+type Groups struct {
+	ctx       context.Context
+	logger    Logger
+	groups    []*clientGroup
+	neighbors *neighbors
+}
+
+// Intercept assigns the request to the first group containing the client.
+// Requests from clients without a group continue with the global lists.
+func (g *Groups) Intercept(
+	_ context.Context,
+	req *Request,
+) (*Request, bool) {
+	client, err := netip.ParseAddrPort(req.client)
+	if err != nil {
+		return req, true
+	}
+
+	ip := client.Addr().Unmap()
+
+	var mac string
+	if g.neighbors != nil {
+		mac = g.neighbors.lookup(ip)
+	}
+
+	for _, cg := range g.groups {
+		if cg.contains(ip, mac) {
+			req.group = cg.Name
+			break
+		}
+	}
+
+	return req, true
+}
+
+// Stage returns a stage which evaluates the requests of a group with the
+// stage of the group registered under the name, and requests without a
+// group with the global stage.
+func (g *Groups) Stage(name Category, global Interceptor) Interceptor {
+	return InterceptorFunc(func(
+		ctx context.Context,
+		req *Request,
+	) (*Request, bool) {
+		cg := g.group(req.group)
+		if cg == nil {
+			return global.Intercept(ctx, req)
+		}
+
+		stage, ok := cg.stages[name]
+		if !ok {
+			return req, true
+		}
+
+		return stage.Intercept(ctx, req)
+	})
+}
+
+// upstream returns the upstreams of the group of the request, or the
+// global upstreams when the group does not configure upstreams.
+func (g *Groups) upstream(req *Request, global chan<- *Request) chan<- *Request {
+	cg := g.group(req.group)
+	if cg == nil || cg.upstream == nil {
+		return global
+	}
+
+	return cg.upstream
+}
+
+func (g *Groups) group(name string) *clientGroup {
+	if name == "" {
+		return nil
+	}
+
+	for _, cg := range g.groups {
+		if cg.Name == name {
+			return cg
+		}
+	}
+
+	return nil
+}
+
+type clientGroup struct {
+	Group
+
+	prefixes []netip.Prefix
+	macs     []string
+
+	// stages are the local, allow, and block stages of the group
+	stages map[Category]Interceptor
+
+	// upstream is nil when the group uses the global upstreams
+	upstream chan<- *Request
+}
+
+func newClientGroup(group Group, local []*Record) (*clientGroup, error) {
+	cg := &clientGroup{
+		Group: group,
+	}
+
+	for _, client := range group.Clients {
+		mac, err := net.ParseMAC(client)
+		if err == nil {
+			cg.macs = append(cg.macs, mac.String())
+			continue
+		}
+
+		prefixes, err := ParsePrefixes(client)
+		if err == nil {
+			cg.prefixes = append(cg.prefixes, prefixes...)
+			continue
+		}
+
+		ips := resolveLocal(client, local)
+		if len(ips) == 0 {
+			return nil, fmt.Errorf(
+				"client group [%s]: unknown client [%s]",
+				group.Name,
+				client,
+			)
+		}
+
+		for _, ip := range ips {
+			cg.prefixes = append(cg.prefixes, netip.PrefixFrom(ip, ip.BitLen()))
+		}
+	}
+
+	return cg, nil
+}
+
+// contains indicates if the client ip or mac belongs to the group.
+func (cg *clientGroup) contains(ip netip.Addr, mac string) bool {
+	if contains(cg.prefixes, ip) {
+		return true
+	}
+
+	if mac == "" {
+		return false
+	}
+
+	for _, m := range cg.macs {
+		if m == mac {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resolveLocal returns the IPs of the direct local records for the name.
+func resolveLocal(name string, local []*Record) []netip.Addr {
+	name = strings.TrimSuffix(name, ".")
+
+	var ips []netip.Addr
+	for _, r := range local {
+		if r.Type != DIRECT || !strings.EqualFold(r.Pattern, name) {
+			continue
+		}
+
+		ip, ok := netip.AddrFromSlice(r.IP)
+		if ok {
+			ips = append(ips, ip.Unmap())
+		}
+	}
+
+	return ips
+}
+
+// neighbors is a cache of the ipv4 neighbor table which maps client IPs
+// to MAC addresses.
+type neighbors struct {
+	path    string
+	refresh time.Duration
+
+	mu   sync.Mutex
+	read time.Time
+	macs map[netip.Addr]string
+}
+
+// lookup returns the MAC address of the ip, reading the neighbor table
+// when the cached table is older than the refresh interval.
+func (n *neighbors) lookup(ip netip.Addr) string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if time.Since(n.read) > n.refresh {
+		n.read = time.Now()
+
+		data, err := os.ReadFile(n.path)
+		if err == nil {
+			n.macs = parseARP(string(data))
+		}
+	}
+
+	return n.macs[ip]
+}
+
+// parseARP parses the neighbor table in the format of /proc/net/arp.
+//
+//	IP address       HW type     Flags       HW address            Mask     Device
+//	192.168.0.2      0x1         0x2         aa:bb:cc:dd:ee:ff     *        eth0
+func parseARP(data string) map[netip.Addr]string {
+	macs := make(map[netip.Addr]string)
+
+	// The header line is skipped as it does not start with an ip
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+
+		ip, err := netip.ParseAddr(fields[0])
+		if err != nil {
+			continue
+		}
+
+		mac, err := net.ParseMAC(fields[3])
+		if err != nil || mac.String() == "00:00:00:00:00:00" {
+			continue
+		}
+
+		macs[ip.Unmap()] = mac.String()
+	}
+
+	return macs
+}
@@ -0,0 +1,182 @@
+package void
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const testARP = `IP address       HW type     Flags       HW address            Mask     Device
+192.168.1.20     0x1         0x2         aa:bb:cc:dd:ee:ff     *        eth0
+192.168.1.21     0x1         0x0         00:00:00:00:00:00     *        eth0
+`
+
+func Test_Groups_Intercept(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	arp := filepath.Join(t.TempDir(), "arp")
+	err := os.WriteFile(arp, []byte(testARP), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	local := []*Record{{
+		Pattern: "tablet.lan",
+		Type:    DIRECT,
+		IP:      net.ParseIP("192.168.1.30"),
+	}}
+
+	groups, err := ClientGroups(ctx, &NOOPLogger{}, local,
+		Group{Name: "servers", Clients: []string{"10.0.0.0/24", "fd00::1"}},
+		Group{Name: "kids", Clients: []string{"AA-BB-CC-DD-EE-FF", "tablet.lan"}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups.neighbors.path = arp
+
+	tests := map[string]struct {
+		client string
+		group  string
+	}{
+		"cidr":       {client: "10.0.0.5:5353", group: "servers"},
+		"ipv6":       {client: "[fd00::1]:5353", group: "servers"},
+		"mac":        {client: "192.168.1.20:5353", group: "kids"},
+		"name":       {client: "192.168.1.30:5353", group: "kids"},
+		"incomplete": {client: "192.168.1.21:5353"},
+		"none":       {client: "192.168.1.40:5353"},
+		"invalid":    {client: "invalid"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := &Request{
+				ctx:    ctx,
+				r:      Question(t, "test.example.tld.", dns.TypeA),
+				client: test.client,
+			}
+
+			_, pass := groups.Intercept(ctx, req)
+			if !pass {
+				t.Fatal("expected request to pass")
+			}
+
+			if req.Group() != test.group {
+				t.Fatalf("expected group [%s], got [%s]", test.group, req.Group())
+			}
+		})
+	}
+}
+
+func Test_ClientGroups_Invalid(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tests := map[string][]Group{
+		"missing-name": {{Clients: []string{"10.0.0.0/24"}}},
+		"duplicate": {
+			{Name: "kids", Clients: []string{"10.0.0.0/24"}},
+			{Name: "kids", Clients: []string{"10.0.1.0/24"}},
+		},
+		"unknown-client": {{Name: "kids", Clients: []string{"tablet.lan"}}},
+	}
+
+	for name, groups := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ClientGroups(ctx, &NOOPLogger{}, nil, groups...)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func Test_Groups_Stage(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	groups, err := ClientGroups(ctx, &NOOPLogger{}, nil,
+		Group{Name: "kids", Clients: []string{"10.0.0.0/24"}},
+		Group{Name: "servers", Clients: []string{"10.0.1.0/24"}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stage := func(name string) Interceptor {
+		return InterceptorFunc(func(
+			_ context.Context,
+			req *Request,
+		) (*Request, bool) {
+			req.record = name
+			return req, true
+		})
+	}
+
+	groups.group("kids").stages = map[Category]Interceptor{
+		BLOCK: stage("kids"),
+	}
+
+	block := groups.Stage(BLOCK, stage("global"))
+
+	tests := map[string]struct {
+		group    string
+		expected string
+	}{
+		"global": {expected: "global"},
+		"group":  {group: "kids", expected: "kids"},
+		"none":   {group: "servers"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := &Request{ctx: ctx, group: test.group}
+
+			_, pass := block.Intercept(ctx, req)
+			if !pass {
+				t.Fatal("expected request to pass")
+			}
+
+			if req.record != test.expected {
+				t.Fatalf("expected stage [%s], got [%s]", test.expected, req.record)
+			}
+		})
+	}
+}
+
+func Test_Neighbors_Refresh(t *testing.T) {
+	arp := filepath.Join(t.TempDir(), "arp")
+	err := os.WriteFile(arp, []byte(testARP), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := &neighbors{path: arp, refresh: time.Hour}
+
+	ip := netip.MustParseAddr("192.168.1.20")
+	if mac := n.lookup(ip); mac != "aa:bb:cc:dd:ee:ff" {
+		t.Fatalf("expected mac, got [%s]", mac)
+	}
+
+	// The table is not read again until the refresh interval passes
+	err = os.WriteFile(arp, nil, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mac := n.lookup(ip); mac != "aa:bb:cc:dd:ee:ff" {
+		t.Fatalf("expected cached mac, got [%s]", mac)
+	}
+
+	n.read = time.Time{}
+	if mac := n.lookup(ip); mac != "" {
+		t.Fatalf("expected no mac, got [%s]", mac)
+	}
+}
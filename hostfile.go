@@ -1,4 +1,4 @@
-package main
+package void
 
 import (
 	"context"
@@ -50,7 +50,9 @@ func (h Hosts) Swap(i, j int) {
 type Host struct {
 	Domain  string `json:"domain"`
 	IP      net.IP `json:"ip"`
+	IPv6    net.IP `json:"ipv6,omitempty"`
 	Type    Type   `json:"type"`
+	Action  Action `json:"action,omitempty"`
 	Comment string `json:"comment"`
 }
 
@@ -59,8 +61,10 @@ func (h *Host) Record(src, cat string, tags ...string) *Record {
 	return &Record{
 		Pattern:  h.Domain,
 		IP:       h.IP,
+		IPv6:     h.IPv6,
 		Comment:  h.Comment,
 		Type:     h.Type,
+		Action:   h.Action,
 		Source:   src,
 		Category: cat,
 		Tags:     tags,
@@ -124,6 +128,13 @@ func Parse(
 		return nil
 	}
 
+	switch tpe {
+	case RPZ:
+		return parseRPZ(ctx, logger, data)
+	case CIDR:
+		return parseCIDR(ctx, logger, data)
+	}
+
 	lines := strings.Split(string(data), "\n")
 
 	for _, line := range lines {
@@ -0,0 +1,560 @@
+package void
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/netutil"
+)
+
+// Default ports of the protocols when no port is provided.
+const (
+	DefaultPort      = 53
+	DefaultTLSPort   = 853
+	DefaultHTTPSPort = 443
+)
+
+const (
+	// DefaultTCPTimeout is the idle timeout for stream based connections
+	// as recommended by RFC 7766 when no timeout is configured.
+	DefaultTCPTimeout = time.Second * 10
+
+	// DefaultTCPConns is the default limit of concurrent connections
+	// accepted by a stream based listener.
+	DefaultTCPConns = 1000
+)
+
+const (
+	// keepaliveUnit is the unit of the edns-tcp-keepalive timeout
+	// value as defined by RFC 7828.
+	keepaliveUnit = time.Millisecond * 100
+)
+
+// listenProtoReg matches the protocols supported by listeners.
+const listenProtoReg = `(udp|tcp|tcp-tls|https|quic){0,1}(?:\:\/\/){0,1}`
+
+// listenReg is a regular expression for matching listen addresses which
+// follow the upstream address format, additionally allowing bracketed
+// IPv6 addresses so that a port is unambiguous
+// <proto>://<ip>[:<port>].
+var listenReg = regexp.MustCompile(
+	fmt.Sprintf(
+		`^%s(%s|%s|\[%s\])%s$`,
+		listenProtoReg,
+		ipv4Reg,
+		ipv6Reg,
+		ipv6Reg,
+		portReg,
+	),
+)
+
+// ListenAddr is a single address a listener is bound to.
+type ListenAddr struct {
+	Proto Protocol
+
+	// IP is the interface address to bind, a nil IP
+	// binds all interfaces of both IPv4 and IPv6
+	IP   net.IP
+	Port uint16
+}
+
+// ParseListen parses the listen addresses in the format
+// <proto>://<ip>[:<port>]. Addresses without a protocol listen on both
+// udp and tcp, and addresses without a port use the default port of
+// the protocol.
+func ParseListen(addresses ...string) ([]ListenAddr, error) {
+	addrs := make([]ListenAddr, 0, len(addresses))
+	for _, address := range addresses {
+		proto, ip, port, err := parseAddr(listenReg, address)
+		if err != nil {
+			return nil, err
+		}
+
+		protos := []Protocol{proto}
+		if proto == "" {
+			protos = []Protocol{UDP, TCP}
+		}
+
+		for _, proto := range protos {
+			p := uint16(port)
+			if p == 0 {
+				p = proto.port()
+			}
+
+			addrs = append(addrs, ListenAddr{
+				Proto: proto,
+				IP:    ip,
+				Port:  p,
+			})
+		}
+	}
+
+	return addrs, nil
+}
+
+// Addr returns the host:port of the listen address.
+func (a ListenAddr) Addr() string {
+	host := ""
+	if a.IP != nil {
+		host = a.IP.String()
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(int(a.Port)))
+}
+
+func (a ListenAddr) String() string {
+	return fmt.Sprintf("%s://%s", a.Proto, a.Addr())
+}
+
+// port returns the default listening port of the protocol.
+func (p Protocol) port() uint16 {
+	switch p {
+	case TLS, QUIC:
+		return DefaultTLSPort
+	case HTTPS:
+		return DefaultHTTPSPort
+	default:
+		return DefaultPort
+	}
+}
+
+// transport returns the transport protocol of the protocol, which is
+// udp for quic and tcp for tcp-tls and https.
+func (p Protocol) transport() Protocol {
+	switch p {
+	case UDP, QUIC:
+		return UDP
+	default:
+		return TCP
+	}
+}
+
+// network returns the network to bind for the address so that specific
+// IPv4 and IPv6 addresses are bound to separate sockets. Addresses
+// without a host bind to both.
+func network(base Protocol, addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return string(base)
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return string(base)
+	}
+
+	if ip.To4() != nil {
+		return string(base) + "4"
+	}
+
+	return string(base) + "6"
+}
+
+// Listeners creates a listener for each of the addresses, all of which
+// pass requests to the same handler. The tls configuration is required
+// for tcp-tls, https, and quic addresses.
+func Listeners(
+	ctx context.Context,
+	logger Logger,
+	handler dns.Handler,
+	tcp *TCPConfig,
+	tlsConfig *tls.Config,
+	addrs ...ListenAddr,
+) ([]Listener, error) {
+	listeners := make([]Listener, 0, len(addrs))
+	for _, addr := range addrs {
+		l, err := newListener(
+			ctx,
+			logger,
+			addr.Proto,
+			addr.Addr(),
+			handler,
+			tcp,
+			tlsConfig,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		listeners = append(listeners, l)
+	}
+
+	return listeners, nil
+}
+
+// SocketListeners creates a listener serving each of the pre-opened
+// sockets, all of which pass requests to the same handler.
+func SocketListeners(
+	ctx context.Context,
+	logger Logger,
+	handler dns.Handler,
+	tcp *TCPConfig,
+	tlsConfig *tls.Config,
+	sockets ...Socket,
+) ([]Listener, error) {
+	listeners := make([]Listener, 0, len(sockets))
+	for _, s := range sockets {
+		l, err := newListener(
+			ctx,
+			logger,
+			s.Proto,
+			s.Addr(),
+			handler,
+			tcp,
+			tlsConfig,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		inherit, ok := l.(inheritor)
+		if !ok {
+			return nil, fmt.Errorf("unsupported socket [%s]", s)
+		}
+
+		err = inherit.inherit(s)
+		if err != nil {
+			return nil, err
+		}
+
+		listeners = append(listeners, l)
+	}
+
+	return listeners, nil
+}
+
+func newListener(
+	ctx context.Context,
+	logger Logger,
+	proto Protocol,
+	addr string,
+	handler dns.Handler,
+	tcp *TCPConfig,
+	tlsConfig *tls.Config,
+) (Listener, error) {
+	switch proto {
+	case HTTPS:
+		return DoHListener(ctx, logger, addr, handler, tcp, tlsConfig)
+	case QUIC:
+		return DoQListener(logger, addr, handler, tcp, tlsConfig)
+	default:
+		return DNSListener(logger, proto, addr, handler, tcp, tlsConfig)
+	}
+}
+
+// inheritor is implemented by listeners which are able to serve
+// a pre-opened socket rather than binding their address.
+type inheritor interface {
+	inherit(s Socket) error
+}
+
+// Listener accepts DNS requests from clients on a single network
+// address and passes them to the handler for evaluation in the
+// pipeline.
+type Listener interface {
+	fmt.Stringer
+
+	// Serve answers requests from clients until the context is
+	// canceled or the listener fails.
+	Serve(ctx context.Context) error
+}
+
+// Serve starts each of the listeners and blocks until all of them have
+// stopped. If any listener fails then the remaining listeners are shut
+// down and the errors are returned.
+func Serve(ctx context.Context, logger Logger, listeners ...Listener) error {
+	err := checkNil(ctx, logger)
+	if err != nil {
+		return err
+	}
+
+	if len(listeners) == 0 {
+		return errors.New("no listeners configured")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func(l Listener) {
+			logger.Infow(
+				"listener started",
+				"listener", l.String(),
+			)
+
+			err := l.Serve(ctx)
+			if err != nil {
+				// Shutdown the rest of the listeners
+				cancel()
+				err = fmt.Errorf("%s: %w", l, err)
+			}
+
+			errs <- err
+		}(l)
+	}
+
+	var errList []error
+	for range listeners {
+		err := <-errs
+		if err != nil {
+			errList = append(errList, err)
+		}
+	}
+
+	return errors.Join(errList...)
+}
+
+// TCPConfig defines the connection handling for stream based listeners.
+type TCPConfig struct {
+	// Timeout is the time an idle connection is kept open
+	// waiting for further queries.
+	Timeout time.Duration
+
+	// Connections is the maximum number of concurrent
+	// connections, zero or less is unlimited.
+	Connections int
+
+	// Proxies are the trusted proxies which send the PROXY
+	// protocol header identifying the client of the connection.
+	Proxies []netip.Prefix
+}
+
+func (c *TCPConfig) timeout() time.Duration {
+	if c == nil || c.Timeout <= 0 {
+		return DefaultTCPTimeout
+	}
+
+	return c.Timeout
+}
+
+func (c *TCPConfig) limit(l net.Listener) net.Listener {
+	if c == nil || c.Connections <= 0 {
+		return l
+	}
+
+	return netutil.LimitListener(l, c.Connections)
+}
+
+// ServerTLSConfig loads the certificate and key pair from the provided
+// paths and returns a tls configuration for serving clients.
+func ServerTLSConfig(certFile, keyFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}, nil
+}
+
+// DNSListener creates a listener for the networks supported directly by
+// the dns package (udp, tcp, tcp-tls) at the provided address. The tls
+// configuration is required for DNS-over-TLS (RFC 7858) listeners.
+func DNSListener(
+	logger Logger,
+	proto Protocol,
+	addr string,
+	handler dns.Handler,
+	tcp *TCPConfig,
+	tlsConfig *tls.Config,
+) (Listener, error) {
+	err := checkNil(logger, handler)
+	if err != nil {
+		return nil, err
+	}
+
+	switch proto {
+	case UDP, TCP:
+	case TLS:
+		if tlsConfig == nil {
+			return nil, fmt.Errorf("missing tls configuration for [%s]", addr)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported listener protocol [%s]", proto)
+	}
+
+	return &dnsListener{
+		proto:   proto,
+		addr:    addr,
+		handler: handler,
+		tcp:     tcp,
+		tls:     tlsConfig,
+		logger:  logger,
+	}, nil
+}
+
+type dnsListener struct {
+	proto   Protocol
+	addr    string
+	handler dns.Handler
+	tcp     *TCPConfig
+	tls     *tls.Config
+	logger  Logger
+	socket  Socket
+}
+
+func (l *dnsListener) inherit(s Socket) error {
+	if (l.proto == UDP) != (s.PacketConn != nil) {
+		return fmt.Errorf("socket type mismatch for [%s]", s)
+	}
+
+	l.socket = s
+	return nil
+}
+
+func (l *dnsListener) String() string {
+	return fmt.Sprintf("%s://%s", l.proto, l.addr)
+}
+
+func (l *dnsListener) Serve(ctx context.Context) error {
+	srv := &dns.Server{
+		Addr:    l.addr,
+		Net:     string(l.proto),
+		Handler: l.handler,
+	}
+
+	switch l.proto {
+	case UDP:
+		pc := l.socket.PacketConn
+		if pc == nil {
+			var err error
+			pc, err = net.ListenPacket(network(UDP, l.addr), l.addr)
+			if err != nil {
+				return err
+			}
+		}
+
+		srv.PacketConn = pc
+		srv.UDPSize = ednsUDPSize
+		srv.Handler = edns(l.handler, true)
+	default:
+		ln := l.socket.Listener
+		if ln == nil {
+			var err error
+			ln, err = net.Listen(network(TCP, l.addr), l.addr)
+			if err != nil {
+				return err
+			}
+		}
+
+		ln = l.tcp.proxy(l.tcp.limit(ln))
+		if l.proto == TLS {
+			ln = tls.NewListener(ln, l.tls)
+		}
+
+		timeout := l.tcp.timeout()
+		srv.Listener = ln
+		srv.IdleTimeout = func() time.Duration { return timeout }
+		srv.Handler = keepalive(edns(l.handler, false), timeout)
+	}
+
+	return activate(ctx, l.logger, srv)
+}
+
+// activate serves the pre-configured listener of the dns server until the
+// context is canceled, at which point the server is gracefully shutdown.
+func activate(ctx context.Context, logger Logger, srv *dns.Server) error {
+	started := make(chan struct{})
+	srv.NotifyStartedFunc = func() { close(started) }
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-done:
+			return
+		case <-started:
+		}
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			err := srv.ShutdownContext(context.Background())
+			if err != nil {
+				logger.Errorw(
+					"failed to gracefully shutdown server",
+					"net", srv.Net,
+					"error", err,
+				)
+			}
+		}
+	}()
+
+	return srv.ActivateAndServe()
+}
+
+// keepalive wraps the handler for stream based listeners so that clients
+// which signal support for edns-tcp-keepalive (RFC 7828) are informed of
+// the idle timeout of the connection.
+func keepalive(next dns.Handler, timeout time.Duration) dns.Handler {
+	return dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		opt := req.IsEdns0()
+		if opt == nil || !hasKeepalive(opt) {
+			next.ServeDNS(w, req)
+			return
+		}
+
+		next.ServeDNS(&keepaliveWriter{w, timeout}, req)
+	})
+}
+
+func hasKeepalive(opt *dns.OPT) bool {
+	for _, o := range opt.Option {
+		if o.Option() == dns.EDNS0TCPKEEPALIVE {
+			return true
+		}
+	}
+
+	return false
+}
+
+// keepaliveWriter adds the edns-tcp-keepalive option to the responses
+// of clients which included the option in their request.
+type keepaliveWriter struct {
+	dns.ResponseWriter
+	timeout time.Duration
+}
+
+func (k *keepaliveWriter) WriteMsg(res *dns.Msg) error {
+	// Copy the response since it may be shared with the cache
+	res = res.Copy()
+
+	opt := res.IsEdns0()
+	if opt == nil {
+		res.SetEdns0(dns.MinMsgSize, false)
+		opt = res.IsEdns0()
+	}
+
+	if !hasKeepalive(opt) {
+		opt.Option = append(opt.Option, &dns.EDNS0_TCP_KEEPALIVE{
+			Code:    dns.EDNS0TCPKEEPALIVE,
+			Timeout: keepaliveTimeout(k.timeout),
+		})
+	}
+
+	return k.ResponseWriter.WriteMsg(res)
+}
+
+// keepaliveTimeout converts the timeout to the units of the
+// edns-tcp-keepalive option, capping it at the maximum value.
+func keepaliveTimeout(timeout time.Duration) uint16 {
+	units := timeout / keepaliveUnit
+	if units > math.MaxUint16 {
+		return math.MaxUint16
+	}
+
+	return uint16(units)
+}
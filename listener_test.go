@@ -0,0 +1,470 @@
+package void
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// freePort returns a port which is currently free for both
+// udp and tcp on the loopback interface.
+func freePort(t *testing.T) string {
+	t.Helper()
+
+	for i := 0; i < 10; i++ {
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		addr := pc.LocalAddr().String()
+		pc.Close()
+
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			continue
+		}
+
+		ln.Close()
+		return addr
+	}
+
+	t.Fatal("unable to find a free port")
+	return ""
+}
+
+// selfSigned writes a self-signed certificate for localhost to the test
+// directory returning the paths to the certificate and key along with a
+// pool trusting the certificate.
+func selfSigned(t *testing.T) (string, string, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("::1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	err = os.WriteFile(
+		certFile,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		0o600,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(
+		keyFile,
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}),
+		0o600,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	return certFile, keyFile, pool
+}
+
+// answer responds to each of the requests with a single A record.
+func answer(ctx context.Context, requests <-chan *Request) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case req, ok := <-requests:
+			if !ok {
+				return
+			}
+
+			res := new(dns.Msg).SetReply(req.r)
+			res.Answer = append(res.Answer, &dns.A{
+				Hdr: dns.RR_Header{
+					Name:   req.r.Question[0].Name,
+					Rrtype: dns.TypeA,
+					Class:  dns.ClassINET,
+					Ttl:    DEFAULTTTL,
+				},
+				A: net.ParseIP("192.168.0.1"),
+			})
+
+			_ = req.Answer(res)
+		}
+	}
+}
+
+// serveTest starts the listeners and returns a function which stops
+// them, failing the test if the listeners returned an error.
+func serveTest(t *testing.T, ctx context.Context, listeners ...Listener) func() {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(ctx)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- Serve(ctx, &NOOPLogger{}, listeners...)
+	}()
+
+	// Allow the listeners to bind
+	time.Sleep(time.Millisecond * 50)
+
+	return func() {
+		cancel()
+		err := <-errs
+		if err != nil {
+			t.Fatalf("unexpected serve error: %v", err)
+		}
+	}
+}
+
+func Test_Listener_UDP_TCP(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logger := &NOOPLogger{}
+	handler, requests := Convert(ctx, logger, false)
+	go answer(ctx, requests)
+
+	addr := freePort(t)
+	tcp := &TCPConfig{Timeout: time.Second * 3, Connections: 1}
+
+	listeners := []Listener{}
+	for _, proto := range []Protocol{UDP, TCP} {
+		l, err := DNSListener(logger, proto, addr, dns.HandlerFunc(handler), tcp, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		listeners = append(listeners, l)
+	}
+
+	stop := serveTest(t, ctx, listeners...)
+	defer stop()
+
+	tests := map[string]struct {
+		net       string
+		keepalive bool
+	}{
+		"udp":           {net: "udp"},
+		"tcp":           {net: "tcp"},
+		"tcp-keepalive": {net: "tcp", keepalive: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := Question(t, "test.example.tld.", dns.TypeA)
+			if test.keepalive {
+				m.SetEdns0(dns.DefaultMsgSize, false)
+				opt := m.IsEdns0()
+				opt.Option = append(opt.Option, &dns.EDNS0_TCP_KEEPALIVE{
+					Code: dns.EDNS0TCPKEEPALIVE,
+				})
+			}
+
+			c := &dns.Client{Net: test.net, Timeout: time.Second}
+			res, _, err := c.ExchangeContext(ctx, m, addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(res.Answer) != 1 {
+				t.Fatalf("expected 1 answer, got %d", len(res.Answer))
+			}
+
+			if !test.keepalive {
+				return
+			}
+
+			opt := res.IsEdns0()
+			if opt == nil {
+				t.Fatal("expected OPT record in response")
+			}
+
+			for _, o := range opt.Option {
+				ka, ok := o.(*dns.EDNS0_TCP_KEEPALIVE)
+				if !ok {
+					continue
+				}
+
+				expected := keepaliveTimeout(tcp.Timeout)
+				if ka.Timeout != expected {
+					t.Fatalf("expected timeout %d, got %d", expected, ka.Timeout)
+				}
+
+				return
+			}
+
+			t.Fatal("expected edns-tcp-keepalive option in response")
+		})
+	}
+}
+
+func Test_Listener_TLS(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logger := &NOOPLogger{}
+	handler, requests := Convert(ctx, logger, false)
+	go answer(ctx, requests)
+
+	certFile, keyFile, pool := selfSigned(t)
+	tlsConfig, err := ServerTLSConfig(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := freePort(t)
+	l, err := DNSListener(
+		logger,
+		TLS,
+		addr,
+		dns.HandlerFunc(handler),
+		nil,
+		tlsConfig,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := serveTest(t, ctx, l)
+	defer stop()
+
+	c := &dns.Client{
+		Net:     string(TLS),
+		Timeout: time.Second,
+		TLSConfig: &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			RootCAs:    pool,
+			ServerName: "localhost",
+		},
+	}
+
+	res, _, err := c.ExchangeContext(
+		ctx,
+		Question(t, "test.example.tld.", dns.TypeA),
+		addr,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Answer) != 1 {
+		t.Fatalf("expected 1 answer, got %d", len(res.Answer))
+	}
+}
+
+func Test_DNSListener_MissingTLS(t *testing.T) {
+	_, err := DNSListener(
+		&NOOPLogger{},
+		TLS,
+		"127.0.0.1:0",
+		dns.DefaultServeMux,
+		nil,
+		nil,
+	)
+	if err == nil {
+		t.Fatal("expected error for missing tls configuration")
+	}
+}
+
+func Test_DNSListener_InvalidProto(t *testing.T) {
+	_, err := DNSListener(
+		&NOOPLogger{},
+		Protocol("invalid"),
+		"127.0.0.1:0",
+		dns.DefaultServeMux,
+		nil,
+		nil,
+	)
+	if err == nil {
+		t.Fatal("expected error for invalid protocol")
+	}
+}
+
+func Test_ParseListen(t *testing.T) {
+	tests := map[string]struct {
+		address  string
+		expected []string
+		error    bool
+	}{
+		"ipv4-no-proto": {
+			address:  "127.0.0.1:5353",
+			expected: []string{"udp://127.0.0.1:5353", "tcp://127.0.0.1:5353"},
+		},
+		"ipv4-no-proto-no-port": {
+			address:  "192.168.0.2",
+			expected: []string{"udp://192.168.0.2:53", "tcp://192.168.0.2:53"},
+		},
+		"ipv4-udp": {
+			address:  "udp://0.0.0.0:53",
+			expected: []string{"udp://0.0.0.0:53"},
+		},
+		"ipv4-tls-default-port": {
+			address:  "tcp-tls://192.168.0.2",
+			expected: []string{"tcp-tls://192.168.0.2:853"},
+		},
+		"ipv4-https-default-port": {
+			address:  "https://192.168.0.2",
+			expected: []string{"https://192.168.0.2:443"},
+		},
+		"ipv4-quic-default-port": {
+			address:  "quic://192.168.0.2",
+			expected: []string{"quic://192.168.0.2:853"},
+		},
+		"ipv6-bracketed": {
+			address:  "udp://[::1]:5353",
+			expected: []string{"udp://[::1]:5353"},
+		},
+		"ipv6-unspecified": {
+			address:  "tcp://[::]",
+			expected: []string{"tcp://[::]:53"},
+		},
+		"ipv6-unbracketed": {
+			address:  "tcp://fe80::1",
+			expected: []string{"tcp://[fe80::1]:53"},
+		},
+		"invalid-proto": {
+			address: "notaproto://127.0.0.1",
+			error:   true,
+		},
+		"invalid-port": {
+			address: "udp://127.0.0.1:700000",
+			error:   true,
+		},
+		"invalid-hostname": {
+			address: "udp://localhost:53",
+			error:   true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			addrs, err := ParseListen(test.address)
+			if err != nil {
+				if test.error {
+					return
+				}
+
+				t.Fatal(err)
+			}
+
+			if test.error {
+				t.Fatalf("expected error, got %v", addrs)
+			}
+
+			if len(addrs) != len(test.expected) {
+				t.Fatalf("expected %d addresses, got %d", len(test.expected), len(addrs))
+			}
+
+			for i, addr := range addrs {
+				if addr.String() != test.expected[i] {
+					t.Fatalf("expected %s, got %s", test.expected[i], addr)
+				}
+			}
+		})
+	}
+}
+
+func Test_Listeners_DualStack(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ln, err := net.ListenPacket("udp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("ipv6 loopback unavailable: %v", err)
+	}
+	ln.Close()
+
+	_, port, err := net.SplitHostPort(freePort(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := &NOOPLogger{}
+	handler, requests := Convert(ctx, logger, false)
+	go answer(ctx, requests)
+
+	// Separate IPv4 and IPv6 sockets sharing a single port
+	addrs, err := ParseListen("127.0.0.1:"+port, "[::1]:"+port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listeners, err := Listeners(
+		ctx,
+		logger,
+		dns.HandlerFunc(handler),
+		nil,
+		nil,
+		addrs...,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := serveTest(t, ctx, listeners...)
+	defer stop()
+
+	for _, addr := range addrs {
+		c := &dns.Client{Net: string(addr.Proto), Timeout: time.Second}
+		res, _, err := c.ExchangeContext(
+			ctx,
+			Question(t, "test.example.tld.", dns.TypeA),
+			addr.Addr(),
+		)
+		if err != nil {
+			t.Fatalf("%s: %v", addr, err)
+		}
+
+		if len(res.Answer) != 1 {
+			t.Fatalf("%s: expected 1 answer, got %d", addr, len(res.Answer))
+		}
+	}
+}
@@ -1,7 +1,9 @@
-package main
+package void
 
 import (
 	"context"
+	"slices"
+	"strings"
 
 	"github.com/miekg/dns"
 )
@@ -22,9 +24,40 @@ func LocalResolver(
 		Matcher: m,
 		ctx:     ctx,
 		logger:  logger,
+		ptrs:    reverse(records...),
+		ptrTTL:  DEFAULTTTL,
 	}, nil
 }
 
+// reverse maps the reverse names (in-addr.arpa and ip6.arpa) of the IPs of
+// the direct records to their names, where the names of an IP shared by
+// multiple records are sorted so that the PTR answers are deterministic.
+func reverse(records ...*Record) map[string][]string {
+	ptrs := make(map[string][]string)
+	for _, r := range records {
+		if r.Type != DIRECT || r.IP == nil || r.IP.IsUnspecified() {
+			continue
+		}
+
+		name, err := dns.ReverseAddr(r.IP.String())
+		if err != nil {
+			continue
+		}
+
+		ptrs[name] = append(
+			ptrs[name],
+			dns.Fqdn(strings.ToLower(r.Pattern)),
+		)
+	}
+
+	for name, targets := range ptrs {
+		slices.Sort(targets)
+		ptrs[name] = slices.Compact(targets)
+	}
+
+	return ptrs
+}
+
 // Local is the local DNS resolver implementation which handles the locally
 // configured DNS records. This does NOT include any blocked or allowed
 // records nor does it handle caching upstream DNS records. This is strictly
@@ -33,6 +66,11 @@ type Local struct {
 	*Matcher
 	ctx    context.Context
 	logger Logger
+
+	// ptrs maps the reverse names of the IPs of the local records to
+	// their names, answered with the ptrTTL
+	ptrs   map[string][]string
+	ptrTTL uint32
 }
 
 // Intercept implements the stream.InterceptFunc which
@@ -42,6 +80,10 @@ func (l *Local) Intercept(
 	ctx context.Context,
 	req *Request,
 ) (*Request, bool) {
+	if req.r.Question[0].Qtype == dns.TypePTR {
+		return l.ptr(req)
+	}
+
 	if req.r.Question[0].Qtype != dns.TypeA &&
 		req.r.Question[0].Qtype != dns.TypeAAAA {
 		return req, true
@@ -85,3 +127,48 @@ func (l *Local) Intercept(
 
 	return nil, false
 }
+
+// ptr answers the reverse lookups of the IPs of the local records.
+func (l *Local) ptr(req *Request) (*Request, bool) {
+	q := req.r.Question[0]
+
+	targets, ok := l.ptrs[strings.ToLower(q.Name)]
+	if !ok {
+		return req, true
+	}
+
+	res := (&dns.Msg{}).SetReply(req.r)
+	for _, target := range targets {
+		res.Answer = append(res.Answer, &dns.PTR{
+			Hdr: dns.RR_Header{
+				Name:   q.Name,
+				Rrtype: dns.TypePTR,
+				Class:  dns.ClassINET,
+				Ttl:    l.ptrTTL,
+			},
+			Ptr: target,
+		})
+	}
+
+	err := req.Answer(res)
+	if err != nil {
+		l.logger.Errorw(
+			"failed to answer request",
+			"server", "local-resolver",
+			"category", LOCAL,
+			"error", err,
+			"record", req.String(),
+		)
+	}
+
+	l.logger.Debugw(
+		"answered request",
+		"server", "local-resolver",
+		"category", LOCAL,
+		"name", q.Name,
+		"type", dns.Type(q.Qtype),
+		"ptr", targets,
+	)
+
+	return nil, false
+}
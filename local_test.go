@@ -1,4 +1,4 @@
-package main
+package void
 
 import (
 	"context"
@@ -117,3 +117,85 @@ func Test_Local_Intercept(t *testing.T) {
 		})
 	}
 }
+
+func Test_Local_Intercept_PTR(t *testing.T) {
+	pctx, pcancel := context.WithCancel(context.Background())
+	defer pcancel()
+
+	local, err := LocalResolver(pctx, &NOOPLogger{},
+		&Record{Pattern: "nas.lan", Type: DIRECT, IP: net.ParseIP("192.168.0.10")},
+		&Record{Pattern: "files.lan", Type: DIRECT, IP: net.ParseIP("192.168.0.10")},
+		&Record{Pattern: "NAS.lan", Type: DIRECT, IP: net.ParseIP("192.168.0.10")},
+		&Record{Pattern: "printer.lan", Type: DIRECT, IP: net.ParseIP("fd00::20")},
+		&Record{Pattern: "blackhole.lan", Type: DIRECT, IP: net.ParseIP("0.0.0.0")},
+		&Record{Pattern: "*.dev.lan", Type: WILDCARD, IP: net.ParseIP("192.168.0.30")},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	local.ptrTTL = 60
+
+	tests := map[string]struct {
+		name     string
+		expected []string
+	}{
+		"ipv4": {
+			name:     "10.0.168.192.in-addr.arpa.",
+			expected: []string{"files.lan.", "nas.lan."},
+		},
+		"ipv6": {
+			name: "0.2.0.0.0.0.0.0.0.0.0.0.0.0.0.0." +
+				"0.0.0.0.0.0.0.0.0.0.0.0.0.0.d.f.ip6.arpa.",
+			expected: []string{"printer.lan."},
+		},
+		"unspecified": {
+			name: "0.0.0.0.in-addr.arpa.",
+		},
+		"wildcard": {
+			name: "30.0.168.192.in-addr.arpa.",
+		},
+		"unknown": {
+			name: "1.0.168.192.in-addr.arpa.",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(pctx)
+			defer cancel()
+
+			w := &TestWriter{}
+			req := &Request{
+				ctx:    ctx,
+				cancel: cancel,
+				w:      w,
+				r:      Question(t, test.name, dns.TypePTR),
+			}
+
+			_, pass := local.Intercept(ctx, req)
+			if pass != (len(test.expected) == 0) {
+				t.Fatalf("expected pass %v, got %v", len(test.expected) == 0, pass)
+			}
+
+			if pass {
+				return
+			}
+
+			if len(w.response.Answer) != len(test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, w.response.Answer)
+			}
+
+			for i, rr := range w.response.Answer {
+				ptr, ok := rr.(*dns.PTR)
+				if !ok || ptr.Ptr != test.expected[i] {
+					t.Fatalf("expected ptr %s, got %s", test.expected[i], rr)
+				}
+
+				if ptr.Hdr.Ttl != 60 {
+					t.Fatalf("expected ttl 60, got %d", ptr.Hdr.Ttl)
+				}
+			}
+		})
+	}
+}
@@ -1,8 +1,7 @@
-package main
+package void
 
 import (
 	"context"
-	"fmt"
 	"time"
 
 	"github.com/miekg/dns"
@@ -29,24 +28,30 @@ func Convert(
 	return func(w dns.ResponseWriter, req *dns.Msg) {
 		ctx, cancel := context.WithCancel(pCtx)
 
-		var writer Writer = w
+		r := &Request{
+			ctx:     ctx,
+			cancel:  cancel,
+			w:       w,
+			r:       req,
+			server:  w.LocalAddr().String(),
+			client:  w.RemoteAddr().String(),
+			network: w.LocalAddr().Network(),
+			id:      requestID.Add(1),
+			start:   time.Now(),
+		}
+
 		if metrics {
-			writer = &metricWriter{
-				ctx:    ctx,
+			m := &metricWriter{
 				logger: logger,
-				req:    req,
-				start:  time.Now(),
+				req:    r,
 				next:   w.WriteMsg,
 			}
-		}
 
-		r := &Request{
-			ctx:    ctx,
-			cancel: cancel,
-			w:      writer,
-			r:      req,
-			server: w.LocalAddr().String(),
-			client: w.RemoteAddr().String(),
+			// The response rate limit may drop or truncate the response
+			// once it is written
+			m.limited, _ = w.(limitedWriter)
+
+			r.w = m
 		}
 
 		select {
@@ -59,37 +64,29 @@ func Convert(
 	}, out
 }
 
+// metricWriter emits the trace of the request once the response
+// is written.
 type metricWriter struct {
-	ctx    context.Context
-	logger Logger
-	req    *dns.Msg
-	start  time.Time
-	next   func(*dns.Msg) error
+	logger  Logger
+	req     *Request
+	next    func(*dns.Msg) error
+	limited limitedWriter
 }
 
 func (m *metricWriter) WriteMsg(res *dns.Msg) error {
 	defer func() {
+		t := m.req.trace(res)
+		if m.limited != nil {
+			t.Limited = m.limited.limited()
+		}
+
 		m.logger.Debugw(
 			"wrote response",
-			"duration", time.Since(m.start),
-			"name", res.Question[0].Name,
-			"type", dns.Type(res.Question[0].Qtype),
-			"answers", res.Answer,
+			"id", t.ID,
+			"duration", t.Duration,
+			"trace", t,
 		)
 	}()
 
 	return m.next(res)
 }
-
-type Metric struct {
-	Domain   string
-	Duration time.Duration
-}
-
-func (m *Metric) String() string {
-	return fmt.Sprintf("%s %s", m.Domain, m.Duration)
-}
-
-func (m *Metric) Event() string {
-	return m.String()
-}
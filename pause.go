@@ -0,0 +1,211 @@
+package void
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// PauseEvent is logged when blocking is paused or resumed, either
+// globally or for a client group.
+type PauseEvent struct {
+	Msg      string        `json:"msg"`
+	Group    string        `json:"group,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+	Until    time.Time     `json:"until,omitempty"`
+}
+
+func (e *PauseEvent) String() string {
+	group := "global"
+	if e.Group != "" {
+		group = e.Group
+	}
+
+	if e.Until.IsZero() {
+		return fmt.Sprintf("%s: %s", e.Msg, group)
+	}
+
+	return fmt.Sprintf(
+		"%s: %s | until: %s",
+		e.Msg,
+		group,
+		e.Until.Format(time.RFC3339),
+	)
+}
+
+func (e *PauseEvent) Event() string {
+	return e.String()
+}
+
+// NewPauses creates the pauses of blocking, where blocking is paused
+// globally or for one of the client groups.
+func NewPauses(
+	ctx context.Context,
+	logger Logger,
+	groups ...string,
+) (*Pauses, error) {
+	err := checkNil(ctx, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool, len(groups))
+	for _, g := range groups {
+		known[g] = true
+	}
+
+	return &Pauses{
+		ctx:    ctx,
+		logger: logger,
+		groups: known,
+		until:  make(map[string]time.Time),
+		timers: make(map[string]*time.Timer),
+		now:    time.Now,
+	}, nil
+}
+
+// Pauses temporarily disables blocking until each pause expires or is
+// resumed. The empty group is the global pause which applies to every
+// client.
+type Pauses struct {
+	ctx    context.Context
+	logger Logger
+	groups map[string]bool
+
+	mu     sync.Mutex
+	until  map[string]time.Time
+	timers map[string]*time.Timer
+	now    func() time.Time
+}
+
+// Pause pauses blocking for the group for the duration, replacing any
+// existing pause of the group.
+func (p *Pauses) Pause(group string, d time.Duration) (*PauseEvent, error) {
+	if d <= 0 {
+		return nil, fmt.Errorf("invalid pause duration [%s]", d)
+	}
+
+	if group != "" && !p.groups[group] {
+		return nil, fmt.Errorf("unknown client group [%s]", group)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	until := p.now().Add(d)
+	p.until[group] = until
+
+	if t, ok := p.timers[group]; ok {
+		t.Stop()
+	}
+
+	// The pause is enforced by the time, the timer only logs
+	// the expiration
+	p.timers[group] = time.AfterFunc(d, func() {
+		p.expire(group, until)
+	})
+
+	e := &PauseEvent{
+		Msg:      "paused",
+		Group:    group,
+		Duration: d,
+		Until:    until,
+	}
+	p.log(e)
+
+	return e, nil
+}
+
+// Resume resumes blocking for the group, returning false if the group
+// was not paused.
+func (p *Pauses) Resume(group string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	until, ok := p.until[group]
+	if !ok || !p.now().Before(until) {
+		return false
+	}
+
+	p.remove(group)
+	p.log(&PauseEvent{Msg: "resumed", Group: group})
+
+	return true
+}
+
+// Paused indicates if blocking is paused globally or for the group.
+func (p *Pauses) Paused(group string) bool {
+	if p == nil {
+		return false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	if until, ok := p.until[""]; ok && now.Before(until) {
+		return true
+	}
+
+	if group == "" {
+		return false
+	}
+
+	until, ok := p.until[group]
+	return ok && now.Before(until)
+}
+
+// Active returns the pauses which have not expired.
+func (p *Pauses) Active() []*PauseEvent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+
+	active := make([]*PauseEvent, 0, len(p.until))
+	for group, until := range p.until {
+		if !now.Before(until) {
+			continue
+		}
+
+		active = append(active, &PauseEvent{
+			Msg:      "paused",
+			Group:    group,
+			Duration: until.Sub(now),
+			Until:    until,
+		})
+	}
+
+	return active
+}
+
+// expire removes the pause of the group if it has not been replaced.
+func (p *Pauses) expire(group string, until time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.until[group].Equal(until) {
+		return
+	}
+
+	p.remove(group)
+	p.log(&PauseEvent{Msg: "expired", Group: group})
+}
+
+func (p *Pauses) remove(group string) {
+	if t, ok := p.timers[group]; ok {
+		t.Stop()
+	}
+
+	delete(p.timers, group)
+	delete(p.until, group)
+}
+
+func (p *Pauses) log(e *PauseEvent) {
+	p.logger.Infow(
+		e.Msg,
+		"category", PAUSE,
+		"event", e,
+	)
+}
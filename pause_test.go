@@ -0,0 +1,320 @@
+package void
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func Test_Pauses(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tests := map[string]struct {
+		pause  string
+		group  string
+		paused bool
+	}{
+		"global-no-group": {pause: "", group: "", paused: true},
+		"global-group":    {pause: "", group: "kids", paused: true},
+		"group":           {pause: "kids", group: "kids", paused: true},
+		"other-group":     {pause: "kids", group: "servers"},
+		"group-no-group":  {pause: "kids", group: ""},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p, err := NewPauses(ctx, &NOOPLogger{}, "kids", "servers")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			now := time.Now()
+			p.now = func() time.Time { return now }
+
+			_, err = p.Pause(test.pause, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if paused := p.Paused(test.group); paused != test.paused {
+				t.Fatalf("expected paused %v, got %v", test.paused, paused)
+			}
+
+			// The pause expires on its own
+			now = now.Add(time.Minute)
+			if p.Paused(test.group) {
+				t.Fatal("expected pause to expire")
+			}
+		})
+	}
+}
+
+func Test_Pauses_Resume(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p, err := NewPauses(ctx, &NOOPLogger{}, "kids")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Resume("kids") {
+		t.Fatal("expected resume without pause to fail")
+	}
+
+	_, err = p.Pause("kids", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.Active()) != 1 {
+		t.Fatal("expected active pause")
+	}
+
+	if !p.Resume("kids") {
+		t.Fatal("expected resume")
+	}
+
+	if p.Paused("kids") || len(p.Active()) != 0 {
+		t.Fatal("expected blocking to resume")
+	}
+
+	_, err = p.Pause("unknown", time.Minute)
+	if err == nil {
+		t.Fatal("expected error for unknown group")
+	}
+
+	_, err = p.Pause("", 0)
+	if err == nil {
+		t.Fatal("expected error for invalid duration")
+	}
+}
+
+func Test_Pauses_Expire(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p, err := NewPauses(ctx, &NOOPLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = p.Pause("", time.Millisecond*10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.After(time.Second)
+	for {
+		p.mu.Lock()
+		_, ok := p.until[""]
+		p.mu.Unlock()
+
+		if !ok {
+			return
+		}
+
+		select {
+		case <-deadline:
+			t.Fatal("expected pause to be removed")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func Test_Block_Intercept_Paused(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	block, err := BlockResolver(ctx, &NOOPLogger{},
+		&Record{Pattern: "blocked.example.tld", Type: DIRECT},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block.pauses, err = NewPauses(ctx, &NOOPLogger{}, "kids")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = block.pauses.Pause("kids", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := &Request{
+		ctx:    ctx,
+		cancel: cancel,
+		w:      &TestWriter{},
+		r:      Question(t, "blocked.example.tld.", dns.TypeA),
+		group:  "kids",
+	}
+
+	if _, pass := block.Intercept(ctx, req); !pass {
+		t.Fatal("expected paused group to pass")
+	}
+
+	req.group = ""
+	if _, pass := block.Intercept(ctx, req); pass {
+		t.Fatal("expected request to be blocked")
+	}
+}
+
+func Test_ControlHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p, err := NewPauses(ctx, &NOOPLogger{}, "kids")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := ControlHandler(ctx, &NOOPLogger{}, p, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		query  url.Values
+		status int
+		paused bool
+	}{
+		{
+			method: http.MethodPost,
+			path:   controlPause,
+			query:  url.Values{"duration": {"5m"}, "group": {"kids"}},
+			status: http.StatusOK,
+			paused: true,
+		},
+		{
+			method: http.MethodGet,
+			path:   controlPause,
+			status: http.StatusOK,
+			paused: true,
+		},
+		{
+			method: http.MethodPost,
+			path:   controlPause,
+			query:  url.Values{"duration": {"forever"}},
+			status: http.StatusBadRequest,
+			paused: true,
+		},
+		{
+			method: http.MethodPost,
+			path:   controlResume,
+			query:  url.Values{"group": {"kids"}},
+			status: http.StatusNoContent,
+		},
+		{
+			method: http.MethodPost,
+			path:   controlResume,
+			query:  url.Values{"group": {"kids"}},
+			status: http.StatusNotFound,
+		},
+		{
+			method: http.MethodGet,
+			path:   controlResume,
+			status: http.StatusMethodNotAllowed,
+		},
+	}
+
+	// The requests are sequential as each depends on the previous state
+	for _, test := range tests {
+		r := httptest.NewRequest(
+			test.method,
+			test.path+"?"+test.query.Encode(),
+			nil,
+		)
+		r.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+
+		h.ServeHTTP(w, r)
+
+		if w.Code != test.status {
+			t.Fatalf(
+				"%s %s: expected status %d, got %d",
+				test.method,
+				test.path,
+				test.status,
+				w.Code,
+			)
+		}
+
+		if p.Paused("kids") != test.paused {
+			t.Fatalf("%s %s: expected paused %v", test.method, test.path, test.paused)
+		}
+
+		if test.method == http.MethodGet && w.Code == http.StatusOK {
+			var active []*PauseEvent
+			err = json.NewDecoder(w.Body).Decode(&active)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(active) != 1 || active[0].Group != "kids" {
+				t.Fatalf("unexpected active pauses %+v", active)
+			}
+		}
+	}
+}
+
+func Test_ControlHandler_Unauthorized(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p, err := NewPauses(ctx, &NOOPLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = ControlHandler(ctx, &NOOPLogger{}, p, ""); err == nil {
+		t.Fatal("expected error without a token")
+	}
+
+	h, err := ControlHandler(ctx, &NOOPLogger{}, p, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"missing": "",
+		"invalid": "Bearer wrong",
+		"scheme":  "Basic secret",
+		"bare":    "secret",
+	}
+
+	for name, auth := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(
+				http.MethodPost,
+				controlPause+"?duration=5m",
+				nil,
+			)
+			if auth != "" {
+				r.Header.Set("Authorization", auth)
+			}
+
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, r)
+
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
+			}
+
+			if p.Paused("") {
+				t.Fatal("expected unauthorized request to not pause")
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package void
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.atomizer.io/stream"
+)
+
+const defaultLife = time.Millisecond * 100
+const defaultWait = time.Millisecond
+
+// DefaultPipeline is the order of the resolver stages when no order
+// is configured.
+//
+//nolint:gochecknoglobals // default configuration
+var DefaultPipeline = []Category{
+	CACHE,
+	LOCAL,
+	SAFESEARCH,
+	ALLOW,
+	BLOCK,
+	UPSTREAM,
+}
+
+// Interceptor is a stage of the resolver pipeline. The Intercept method
+// matches stream.InterceptFunc[*Request, *Request] where returning false
+// stops the request from continuing down the pipeline, generally because
+// the stage answered the request.
+type Interceptor interface {
+	Intercept(ctx context.Context, req *Request) (*Request, bool)
+}
+
+// InterceptorFunc adapts a function to the Interceptor interface.
+type InterceptorFunc func(ctx context.Context, req *Request) (*Request, bool)
+
+// Intercept calls f(ctx, req).
+func (f InterceptorFunc) Intercept(
+	ctx context.Context,
+	req *Request,
+) (*Request, bool) {
+	return f(ctx, req)
+}
+
+var (
+	_ Interceptor = (*Access)(nil)
+	_ Interceptor = (*Validator)(nil)
+	_ Interceptor = (*Cache)(nil)
+	_ Interceptor = (*Local)(nil)
+	_ Interceptor = (*SafeSearch)(nil)
+	_ Interceptor = (*Allow)(nil)
+	_ Interceptor = (*Block)(nil)
+	_ Interceptor = (*Upstream)(nil)
+)
+
+// NewPipeline creates a pipeline builder where each stage is registered
+// under a name which is then used to order the stages.
+func NewPipeline(logger Logger) (*Pipeline, error) {
+	err := checkNil(logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Pipeline{
+		logger: logger,
+		stages: make(map[Category]stream.InterceptFunc[*Request, *Request]),
+	}, nil
+}
+
+// Pipeline builds the resolver pipeline from the named stages. The
+// upstream stage is reserved for the upstream fan-out which terminates
+// the pipeline.
+type Pipeline struct {
+	logger Logger
+	stages map[Category]stream.InterceptFunc[*Request, *Request]
+}
+
+// Register adds the stage under the name.
+func (p *Pipeline) Register(name Category, stage Interceptor) error {
+	if name == "" || name == UPSTREAM {
+		return fmt.Errorf("invalid stage name [%s]", name)
+	}
+
+	if stage == nil {
+		return fmt.Errorf("nil stage [%s]", name)
+	}
+
+	if _, ok := p.stages[name]; ok {
+		return fmt.Errorf("stage [%s] already registered", name)
+	}
+
+	p.stages[name] = stage.Intercept
+	return nil
+}
+
+// Validate checks that the order only contains registered stages, with no
+// duplicates, and that it ends with the upstream stage.
+func (p *Pipeline) Validate(order ...Category) error {
+	if len(order) == 0 || order[len(order)-1] != UPSTREAM {
+		return fmt.Errorf("pipeline must end with the [%s] stage", UPSTREAM)
+	}
+
+	seen := make(map[Category]bool, len(order))
+	for i, name := range order {
+		if seen[name] {
+			return fmt.Errorf("duplicate stage [%s]", name)
+		}
+		seen[name] = true
+
+		if name == UPSTREAM {
+			if i != len(order)-1 {
+				return fmt.Errorf(
+					"stage [%s] placed after the [%s] stage",
+					order[i+1],
+					UPSTREAM,
+				)
+			}
+
+			continue
+		}
+
+		if _, ok := p.stages[name]; !ok {
+			return fmt.Errorf("unknown stage [%s]", name)
+		}
+	}
+
+	return nil
+}
+
+// Build chains the stages in order, reading requests from in, with the
+// requests which pass every stage sent to upstream.
+func (p *Pipeline) Build(
+	ctx context.Context,
+	in <-chan *Request,
+	upstream chan<- *Request,
+	order ...Category,
+) error {
+	err := p.Validate(order...)
+	if err != nil {
+		return err
+	}
+
+	i := &Initializer[*Request, *Request]{p.logger}
+
+	out := in
+	for _, name := range order[:len(order)-1] {
+		out = i.Scale(ctx, out, traced(name, p.stages[name]))
+	}
+
+	go stream.Pipe(ctx, out, upstream)
+
+	p.logger.Debugw(
+		"pipeline initialized",
+		"stages", order,
+	)
+
+	return nil
+}
+
+// Initializer scales the intercept funcs of the pipeline stages.
+type Initializer[T, U any] struct {
+	logger Logger
+}
+
+func (i *Initializer[T, U]) Scale(
+	ctx context.Context,
+	in <-chan T,
+	f stream.InterceptFunc[T, U],
+) <-chan U {
+	s := stream.Scaler[T, U]{
+		Wait: defaultWait,
+		Life: defaultLife,
+		Fn:   f,
+	}
+
+	out, err := s.Exec(ctx, in)
+	if err != nil {
+		i.logger.Errorw(
+			"error executing scaler",
+			"error", err,
+		)
+	}
+
+	return out
+}
@@ -0,0 +1,211 @@
+package void
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func Test_Pipeline_Validate(t *testing.T) {
+	pass := InterceptorFunc(func(_ context.Context, req *Request) (*Request, bool) {
+		return req, true
+	})
+
+	p, err := NewPipeline(&NOOPLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []Category{CACHE, LOCAL, SAFESEARCH, ALLOW, BLOCK} {
+		err = p.Register(name, pass)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := map[string]struct {
+		order []Category
+		err   bool
+	}{
+		"default": {
+			order: DefaultPipeline,
+		},
+		"block-before-local": {
+			order: []Category{BLOCK, LOCAL, ALLOW, UPSTREAM},
+		},
+		"upstream-only": {
+			order: []Category{UPSTREAM},
+		},
+		"empty": {
+			err: true,
+		},
+		"missing-upstream": {
+			order: []Category{CACHE, LOCAL},
+			err:   true,
+		},
+		"after-upstream": {
+			order: []Category{CACHE, UPSTREAM, BLOCK},
+			err:   true,
+		},
+		"unknown": {
+			order: []Category{CACHE, "unknown", UPSTREAM},
+			err:   true,
+		},
+		"duplicate": {
+			order: []Category{CACHE, BLOCK, CACHE, UPSTREAM},
+			err:   true,
+		},
+		"duplicate-upstream": {
+			order: []Category{UPSTREAM, UPSTREAM},
+			err:   true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := p.Validate(test.order...)
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func Test_Pipeline_Register(t *testing.T) {
+	pass := InterceptorFunc(func(_ context.Context, req *Request) (*Request, bool) {
+		return req, true
+	})
+
+	p, err := NewPipeline(&NOOPLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = p.Register(CACHE, pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Register(CACHE, pass) == nil {
+		t.Fatal("expected error registering duplicate stage")
+	}
+
+	if p.Register(UPSTREAM, pass) == nil {
+		t.Fatal("expected error registering the upstream stage")
+	}
+
+	if p.Register(BLOCK, nil) == nil {
+		t.Fatal("expected error registering nil stage")
+	}
+}
+
+func Test_Pipeline_Build(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	var visited []Category
+
+	stage := func(name Category, answer bool) InterceptorFunc {
+		return func(_ context.Context, req *Request) (*Request, bool) {
+			mu.Lock()
+			visited = append(visited, name)
+			mu.Unlock()
+
+			if answer {
+				_ = req.Block(BlockMode{})
+				return nil, false
+			}
+
+			return req, true
+		}
+	}
+
+	tests := map[string]struct {
+		order    []Category
+		visited  []Category
+		upstream bool
+	}{
+		"block-answers": {
+			order:   []Category{LOCAL, BLOCK, CACHE, UPSTREAM},
+			visited: []Category{LOCAL, BLOCK},
+		},
+		"cache-dropped": {
+			order:    []Category{LOCAL, UPSTREAM},
+			visited:  []Category{LOCAL},
+			upstream: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p, err := NewPipeline(&NOOPLogger{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for name, answer := range map[Category]bool{
+				CACHE: false,
+				LOCAL: false,
+				BLOCK: true,
+			} {
+				err = p.Register(name, stage(name, answer))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			mu.Lock()
+			visited = nil
+			mu.Unlock()
+
+			in := make(chan *Request)
+			upstream := make(chan *Request)
+
+			err = p.Build(ctx, in, upstream, test.order...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rctx, rcancel := context.WithCancel(ctx)
+			defer rcancel()
+
+			w := &TestWriter{}
+			in <- &Request{
+				ctx:    rctx,
+				cancel: rcancel,
+				w:      w,
+				r:      Question(t, "test.example.tld.", dns.TypeA),
+			}
+
+			select {
+			case <-upstream:
+				if !test.upstream {
+					t.Fatal("unexpected upstream request")
+				}
+			case <-rctx.Done():
+				if test.upstream {
+					t.Fatal("expected upstream request")
+				}
+			case <-time.After(time.Second):
+				t.Fatal("timed out")
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if len(visited) != len(test.visited) {
+				t.Fatalf("expected stages %v, got %v", test.visited, visited)
+			}
+
+			for i := range visited {
+				if visited[i] != test.visited[i] {
+					t.Fatalf("expected stages %v, got %v", test.visited, visited)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package void
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// proxyV1Max is the maximum length of a PROXY protocol v1 header
+	// including the CRLF.
+	proxyV1Max = 107
+
+	proxyV2HeaderLen = 16
+	proxyV2Local     = 0x0
+	proxyV2Proxy     = 0x1
+	proxyV2TCP4      = 0x11
+	proxyV2TCP6      = 0x21
+)
+
+var (
+	proxyV1Sig = []byte("PROXY ")
+	proxyV2Sig = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+// proxy wraps the listener so that connections from the trusted proxies
+// must start with a PROXY protocol (v1 or v2) header, which replaces the
+// remote address of the connection with the address of the client.
+// Connections from other sources are served as is.
+func (c *TCPConfig) proxy(l net.Listener) net.Listener {
+	if c == nil || len(c.Proxies) == 0 {
+		return l
+	}
+
+	return &proxyListener{
+		Listener: l,
+		trusted:  c.Proxies,
+		timeout:  c.timeout(),
+	}
+}
+
+type proxyListener struct {
+	net.Listener
+	trusted []netip.Prefix
+	timeout time.Duration
+}
+
+func (l *proxyListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	remote, ok := clientIP(conn.RemoteAddr())
+	if !ok || !contains(l.trusted, remote) {
+		return conn, nil
+	}
+
+	// The header is read on first use of the connection so that slow
+	// proxies do not block the accept loop
+	return &proxyConn{
+		Conn:    conn,
+		reader:  bufio.NewReaderSize(conn, proxyV1Max),
+		timeout: l.timeout,
+	}, nil
+}
+
+// proxyConn is a connection from a trusted proxy which reads the PROXY
+// protocol header before any other data.
+type proxyConn struct {
+	net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+
+	once   sync.Once
+	remote net.Addr
+	err    error
+
+	// deadline is the read deadline set by the server which is
+	// restored after the header is read
+	deadline   time.Time
+	deadlineMu sync.Mutex
+}
+
+func (c *proxyConn) header() error {
+	c.once.Do(func() {
+		c.deadlineMu.Lock()
+		deadline := c.deadline
+		c.deadlineMu.Unlock()
+
+		limit := time.Now().Add(c.timeout)
+		if deadline.IsZero() || limit.Before(deadline) {
+			_ = c.Conn.SetReadDeadline(limit)
+		}
+
+		c.remote, c.err = readProxy(c.reader)
+		if c.err != nil {
+			c.err = fmt.Errorf("invalid proxy header from [%s]: %w", c.Conn.RemoteAddr(), c.err)
+			c.Conn.Close()
+			return
+		}
+
+		_ = c.Conn.SetReadDeadline(deadline)
+	})
+
+	return c.err
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	err := c.header()
+	if err != nil {
+		return 0, err
+	}
+
+	return c.reader.Read(b)
+}
+
+// RemoteAddr returns the client address from the PROXY protocol header,
+// or the address of the proxy when the header does not include one.
+func (c *proxyConn) RemoteAddr() net.Addr {
+	if c.header() != nil || c.remote == nil {
+		return c.Conn.RemoteAddr()
+	}
+
+	return c.remote
+}
+
+func (c *proxyConn) SetDeadline(t time.Time) error {
+	c.setDeadline(t)
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *proxyConn) SetReadDeadline(t time.Time) error {
+	c.setDeadline(t)
+	return c.Conn.SetReadDeadline(t)
+}
+
+func (c *proxyConn) setDeadline(t time.Time) {
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+
+	c.deadline = t
+}
+
+// readProxy reads a PROXY protocol v1 or v2 header returning the source
+// address, which is nil for LOCAL (v2) and UNKNOWN (v1) connections.
+func readProxy(r *bufio.Reader) (net.Addr, error) {
+	sig, err := r.Peek(len(proxyV1Sig))
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.Equal(sig, proxyV1Sig) {
+		return readProxyV1(r)
+	}
+
+	sig, err = r.Peek(len(proxyV2Sig))
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.Equal(sig, proxyV2Sig) {
+		return readProxyV2(r)
+	}
+
+	return nil, errors.New("missing header")
+}
+
+// readProxyV1 reads the human readable header, for example:
+// PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n
+func readProxyV1(r *bufio.Reader) (net.Addr, error) {
+	line := make([]byte, 0, proxyV1Max)
+	for !bytes.HasSuffix(line, []byte("\r\n")) {
+		if len(line) >= proxyV1Max {
+			return nil, errors.New("v1 header too long")
+		}
+
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+
+		line = append(line, b)
+	}
+
+	fields := strings.Split(strings.TrimSuffix(string(line), "\r\n"), " ")
+	if len(fields) < 2 {
+		return nil, errors.New("malformed v1 header")
+	}
+
+	switch fields[1] {
+	case "UNKNOWN":
+		return nil, nil
+	case "TCP4", "TCP6":
+	default:
+		return nil, fmt.Errorf("unsupported v1 protocol [%s]", fields[1])
+	}
+
+	if len(fields) != 6 {
+		return nil, errors.New("malformed v1 header")
+	}
+
+	ip, err := netip.ParseAddr(fields[2])
+	if err != nil {
+		return nil, err
+	}
+
+	if ip.Is4() != (fields[1] == "TCP4") {
+		return nil, fmt.Errorf("address [%s] does not match %s", ip, fields[1])
+	}
+
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, err
+	}
+
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, uint16(port))), nil
+}
+
+// readProxyV2 reads the binary header, ignoring any TLVs.
+func readProxyV2(r *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, proxyV2HeaderLen)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return nil, err
+	}
+
+	version, command := header[12]>>4, header[12]&0xF
+	if version != 2 {
+		return nil, fmt.Errorf("unsupported version [%d]", version)
+	}
+
+	family := header[13]
+	length := binary.BigEndian.Uint16(header[14:16])
+
+	data := make([]byte, length)
+	_, err = io.ReadFull(r, data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch command {
+	case proxyV2Local:
+		// Connections established by the proxy itself (e.g. health checks)
+		return nil, nil
+	case proxyV2Proxy:
+	default:
+		return nil, fmt.Errorf("unsupported command [%d]", command)
+	}
+
+	var ip netip.Addr
+	var port uint16
+	switch family {
+	case proxyV2TCP4:
+		if len(data) < 12 {
+			return nil, errors.New("short v2 ipv4 addresses")
+		}
+
+		ip = netip.AddrFrom4([4]byte(data[0:4]))
+		port = binary.BigEndian.Uint16(data[8:10])
+	case proxyV2TCP6:
+		if len(data) < 36 {
+			return nil, errors.New("short v2 ipv6 addresses")
+		}
+
+		ip = netip.AddrFrom16([16]byte(data[0:16]))
+		port = binary.BigEndian.Uint16(data[32:34])
+	default:
+		// Unsupported families (e.g. unix sockets) keep the proxy address
+		return nil, nil
+	}
+
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, port)), nil
+}
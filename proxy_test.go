@@ -0,0 +1,210 @@
+package void
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func proxyV2(command, family byte, addrs []byte) []byte {
+	header := append([]byte{}, proxyV2Sig...)
+	header = append(header, 0x20|command, family)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(addrs)))
+
+	return append(header, addrs...)
+}
+
+func Test_readProxy(t *testing.T) {
+	v4 := []byte{203, 0, 113, 7, 192, 168, 0, 2, 0x10, 0x92, 0, 53}
+	v6 := append(
+		append(
+			netip.MustParseAddr("2001:db8::7").AsSlice(),
+			netip.MustParseAddr("2001:db8::2").AsSlice()...,
+		),
+		0x10, 0x92, 0, 53,
+	)
+
+	tests := map[string]struct {
+		header   []byte
+		expected string
+		err      bool
+	}{
+		"v1-tcp4": {
+			header:   []byte("PROXY TCP4 203.0.113.7 192.168.0.2 4242 53\r\n"),
+			expected: "203.0.113.7:4242",
+		},
+		"v1-tcp6": {
+			header:   []byte("PROXY TCP6 2001:db8::7 2001:db8::2 4242 53\r\n"),
+			expected: "[2001:db8::7]:4242",
+		},
+		"v1-unknown": {
+			header: []byte("PROXY UNKNOWN\r\n"),
+		},
+		"v1-mismatched-family": {
+			header: []byte("PROXY TCP4 2001:db8::7 2001:db8::2 4242 53\r\n"),
+			err:    true,
+		},
+		"v1-malformed": {
+			header: []byte("PROXY TCP4 203.0.113.7\r\n"),
+			err:    true,
+		},
+		"v1-too-long": {
+			header: []byte("PROXY TCP4 " + strings.Repeat("1", proxyV1Max) + "\r\n"),
+			err:    true,
+		},
+		"v2-tcp4": {
+			header:   proxyV2(proxyV2Proxy, proxyV2TCP4, v4),
+			expected: "203.0.113.7:4242",
+		},
+		"v2-tcp6": {
+			header:   proxyV2(proxyV2Proxy, proxyV2TCP6, v6),
+			expected: "[2001:db8::7]:4242",
+		},
+		"v2-tlvs": {
+			header:   proxyV2(proxyV2Proxy, proxyV2TCP4, append(v4, 0x04, 0, 1, 0)),
+			expected: "203.0.113.7:4242",
+		},
+		"v2-local": {
+			header: proxyV2(proxyV2Local, 0, nil),
+		},
+		"v2-short": {
+			header: proxyV2(proxyV2Proxy, proxyV2TCP4, v4[:6]),
+			err:    true,
+		},
+		"missing": {
+			header: []byte("GET / HTTP/1.1\r\n"),
+			err:    true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			data := append(append([]byte{}, test.header...), "payload"...)
+			r := bufio.NewReader(bytes.NewReader(data))
+
+			addr, err := readProxy(r)
+			if test.err {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := ""
+			if addr != nil {
+				got = addr.String()
+			}
+
+			if got != test.expected {
+				t.Fatalf("expected [%s], got [%s]", test.expected, got)
+			}
+
+			rest := make([]byte, 16)
+			n, _ := r.Read(rest)
+			if string(rest[:n]) != "payload" {
+				t.Fatalf("expected payload after header, got [%s]", rest[:n])
+			}
+		})
+	}
+}
+
+func Test_Listener_Proxy(t *testing.T) {
+	tests := map[string]struct {
+		trusted  string
+		header   []byte
+		expected string // expected client ip, empty for the local client
+	}{
+		"trusted-v1": {
+			trusted:  "127.0.0.0/8",
+			header:   []byte("PROXY TCP4 203.0.113.7 127.0.0.1 4242 53\r\n"),
+			expected: "203.0.113.7",
+		},
+		"trusted-v2": {
+			trusted: "127.0.0.0/8",
+			header: proxyV2(
+				proxyV2Proxy,
+				proxyV2TCP4,
+				[]byte{203, 0, 113, 8, 127, 0, 0, 1, 0x10, 0x92, 0, 53},
+			),
+			expected: "203.0.113.8",
+		},
+		"untrusted": {
+			trusted: "10.0.0.0/8",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			clients := make(chan string, 1)
+			handler := dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+				clients <- w.RemoteAddr().String()
+				_ = w.WriteMsg((&dns.Msg{}).SetReply(req))
+			})
+
+			tcp := &TCPConfig{
+				Timeout: time.Second,
+				Proxies: []netip.Prefix{netip.MustParsePrefix(test.trusted)},
+			}
+
+			addr := freePort(t)
+			l, err := DNSListener(&NOOPLogger{}, TCP, addr, handler, tcp, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer serveTest(t, ctx, l)()
+
+			conn, err := net.Dial("tcp", addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			_, err = conn.Write(test.header)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c := &dns.Conn{Conn: conn}
+			err = c.WriteMsg(Question(t, "test.example.tld.", dns.TypeA))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = c.ReadMsg()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := test.expected
+			if expected == "" {
+				expected = "127.0.0.1"
+			}
+
+			client, _, err := net.SplitHostPort(<-clients)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if client != expected {
+				t.Fatalf("expected client %s, got %s", expected, client)
+			}
+		})
+	}
+}
@@ -0,0 +1,382 @@
+package void
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// doqALPN is the application protocol negotiated for DNS-over-QUIC.
+const doqALPN = "doq"
+
+// DNS-over-QUIC error codes as defined by RFC 9250 section 4.3, used
+// for both connection and stream errors.
+const (
+	doqNoError          = 0x0
+	doqInternalError    = 0x1
+	doqProtocolError    = 0x2
+	doqRequestCancelled = 0x3
+	doqExcessiveLoad    = 0x4
+)
+
+// doqStreams is the maximum number of concurrent queries (streams)
+// a single DNS-over-QUIC client connection is allowed to open.
+const doqStreams = 100
+
+// readDoQ reads a single length prefixed DNS message from the stream.
+func readDoQ(r io.Reader) (*dns.Msg, error) {
+	var length uint16
+	err := binary.Read(r, binary.BigEndian, &length)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, length)
+	_, err = io.ReadFull(r, data)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &dns.Msg{}
+	err = msg.Unpack(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// writeDoQ writes a single length prefixed DNS message to the stream.
+func writeDoQ(w io.Writer, msg *dns.Msg) error {
+	data, err := msg.Pack()
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, 2, 2+len(data))
+	binary.BigEndian.PutUint16(buf, uint16(len(data)))
+
+	_, err = w.Write(append(buf, data...))
+	return err
+}
+
+// DoQListener creates a DNS-over-QUIC (RFC 9250) listener at the
+// provided address.
+func DoQListener(
+	logger Logger,
+	addr string,
+	handler dns.Handler,
+	tcp *TCPConfig,
+	tlsConfig *tls.Config,
+) (Listener, error) {
+	err := checkNil(logger, handler)
+	if err != nil {
+		return nil, err
+	}
+
+	if tlsConfig == nil {
+		return nil, fmt.Errorf("missing tls configuration for [%s]", addr)
+	}
+
+	cfg := tlsConfig.Clone()
+	cfg.MinVersion = tls.VersionTLS13
+	cfg.NextProtos = []string{doqALPN}
+
+	return &doqListener{
+		addr:    addr,
+		handler: edns(handler, false),
+		tcp:     tcp,
+		tls:     cfg,
+		logger:  logger,
+	}, nil
+}
+
+type doqListener struct {
+	addr    string
+	handler dns.Handler
+	tcp     *TCPConfig
+	tls     *tls.Config
+	logger  Logger
+	socket  Socket
+}
+
+func (l *doqListener) inherit(s Socket) error {
+	if s.PacketConn == nil {
+		return fmt.Errorf("socket type mismatch for [%s]", s)
+	}
+
+	l.socket = s
+	return nil
+}
+
+func (l *doqListener) String() string {
+	return fmt.Sprintf("%s://%s", QUIC, l.addr)
+}
+
+func (l *doqListener) Serve(ctx context.Context) error {
+	pc := l.socket.PacketConn
+	if pc == nil {
+		var err error
+		pc, err = net.ListenPacket(network(UDP, l.addr), l.addr)
+		if err != nil {
+			return err
+		}
+	}
+	defer pc.Close()
+
+	ln, err := quic.Listen(pc, l.tls, &quic.Config{
+		MaxIdleTimeout:     l.tcp.timeout(),
+		MaxIncomingStreams: doqStreams,
+	})
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+
+	// Limit the number of concurrent client connections
+	var conns chan struct{}
+	if l.tcp != nil && l.tcp.Connections > 0 {
+		conns = make(chan struct{}, l.tcp.Connections)
+	}
+
+	for {
+		conn, err := ln.Accept(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return err
+		}
+
+		if conns != nil {
+			select {
+			case conns <- struct{}{}:
+			default:
+				_ = conn.CloseWithError(doqExcessiveLoad, "too many connections")
+				continue
+			}
+		}
+
+		go func() {
+			defer func() {
+				if conns != nil {
+					<-conns
+				}
+			}()
+
+			l.serveConn(ctx, conn)
+		}()
+	}
+}
+
+// serveConn accepts the streams of a client connection, where each
+// stream carries a single query and response.
+func (l *doqListener) serveConn(ctx context.Context, conn quic.Connection) {
+	for {
+		stream, err := conn.AcceptStream(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				_ = conn.CloseWithError(doqNoError, "")
+			}
+
+			return
+		}
+
+		go l.serveStream(conn, stream)
+	}
+}
+
+func (l *doqListener) serveStream(conn quic.Connection, stream quic.Stream) {
+	// Abandon streams which are not answered in time
+	_ = stream.SetDeadline(time.Now().Add(l.tcp.timeout()))
+
+	req, err := readDoQ(stream)
+	if err != nil {
+		l.logger.Debugw(
+			"invalid request",
+			"category", QUIC,
+			"client", conn.RemoteAddr().String(),
+			"error", err,
+		)
+
+		// Malformed queries are a protocol error for the whole connection
+		_ = conn.CloseWithError(doqProtocolError, "malformed query")
+		return
+	}
+
+	// RFC 9250 section 4.2.1 requires the message ID to be zero
+	if req.Id != 0 {
+		_ = conn.CloseWithError(doqProtocolError, "non-zero message id")
+		return
+	}
+
+	l.handler.ServeDNS(&quicWriter{conn: conn, stream: stream}, req)
+}
+
+// quicWriter implements the dns.ResponseWriter for a single
+// DNS-over-QUIC stream.
+type quicWriter struct {
+	conn   quic.Connection
+	stream quic.Stream
+	once   sync.Once
+}
+
+var _ dns.ResponseWriter = (*quicWriter)(nil)
+
+func (q *quicWriter) LocalAddr() net.Addr  { return q.conn.LocalAddr() }
+func (q *quicWriter) RemoteAddr() net.Addr { return q.conn.RemoteAddr() }
+
+func (q *quicWriter) WriteMsg(res *dns.Msg) error {
+	err := writeDoQ(q.stream, res)
+	if err != nil {
+		q.stream.CancelWrite(doqInternalError)
+		return err
+	}
+
+	return q.Close()
+}
+
+func (q *quicWriter) Write(data []byte) (int, error) {
+	res := &dns.Msg{}
+	err := res.Unpack(data)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(data), q.WriteMsg(res)
+}
+
+// Close closes the stream indicating the response is complete.
+func (q *quicWriter) Close() error {
+	var err error
+	q.once.Do(func() {
+		q.stream.CancelRead(doqNoError)
+		err = q.stream.Close()
+	})
+
+	return err
+}
+
+func (q *quicWriter) TsigStatus() error     { return nil }
+func (q *quicWriter) TsigTimersOnly(_ bool) {}
+func (q *quicWriter) Hijack()               {}
+
+// quicClient exchanges DNS requests with a DNS-over-QUIC upstream server
+// re-using a single connection for all requests.
+type quicClient struct {
+	tls     *tls.Config
+	timeout time.Duration
+
+	conn   quic.Connection
+	connMu sync.Mutex
+}
+
+func newQUICClient(tlsConfig *tls.Config, timeout time.Duration) *quicClient {
+	cfg := tlsConfig.Clone()
+	cfg.NextProtos = []string{doqALPN}
+
+	return &quicClient{
+		tls:     cfg,
+		timeout: timeout,
+	}
+}
+
+// connect returns the current connection to the upstream or dials a new
+// connection if one does not exist or the current connection is stale.
+func (c *quicClient) connect(
+	ctx context.Context,
+	address string,
+	stale quic.Connection,
+) (quic.Connection, error) {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+
+	if c.conn != nil && c.conn != stale {
+		return c.conn, nil
+	}
+
+	if c.conn != nil {
+		_ = c.conn.CloseWithError(doqNoError, "")
+	}
+
+	conn, err := quic.DialAddr(ctx, address, c.tls, &quic.Config{
+		MaxIdleTimeout: c.timeout,
+	})
+	if err != nil {
+		c.conn = nil
+		return nil, err
+	}
+
+	c.conn = conn
+	return conn, nil
+}
+
+// ExchangeContext implements the exchanger interface for upstreams.
+func (c *quicClient) ExchangeContext(
+	ctx context.Context,
+	req *dns.Msg,
+	address string,
+) (*dns.Msg, time.Duration, error) {
+	start := time.Now()
+
+	conn, err := c.connect(ctx, address, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stream, err := conn.OpenStreamSync(ctx)
+	if err != nil {
+		// The connection may have been closed by the
+		// server, reconnect and retry once
+		conn, err = c.connect(ctx, address, conn)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		stream, err = conn.OpenStreamSync(ctx)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(c.timeout)
+	}
+	_ = stream.SetDeadline(deadline)
+
+	// The message ID must be zero, it is restored on the response
+	msg := req.Copy()
+	msg.Id = 0
+
+	err = writeDoQ(stream, msg)
+	if err != nil {
+		stream.CancelRead(doqRequestCancelled)
+		stream.CancelWrite(doqRequestCancelled)
+		return nil, 0, err
+	}
+
+	// Closing the stream indicates the end of the query
+	err = stream.Close()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	res, err := readDoQ(stream)
+	if err != nil {
+		stream.CancelRead(doqRequestCancelled)
+		return nil, 0, err
+	}
+
+	res.Id = req.Id
+	return res, time.Since(start), nil
+}
@@ -0,0 +1,99 @@
+package void
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func Test_DoQ_Loopback(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logger := &NOOPLogger{}
+	handler, requests := Convert(ctx, logger, false)
+	go answer(ctx, requests)
+
+	certFile, keyFile, pool := selfSigned(t)
+	tlsConfig, err := ServerTLSConfig(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := freePort(t)
+	l, err := DoQListener(logger, addr, dns.HandlerFunc(handler), nil, tlsConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := serveTest(t, ctx, l)
+	defer stop()
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upPort, err := net.LookupPort("udp", port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := newQUICClient(&tls.Config{
+		MinVersion: tls.VersionTLS13,
+		RootCAs:    pool,
+	}, time.Second)
+
+	u := &Upstream{
+		proto:   QUIC,
+		address: net.ParseIP(host),
+		port:    uint16(upPort),
+		logger:  logger,
+		client:  client,
+	}
+
+	// Multiple requests share a single connection
+	for _, domain := range []string{"one.example.tld.", "two.example.tld."} {
+		w := &TestWriter{}
+		req := Question(t, domain, dns.TypeA)
+		reqCtx, reqCancel := context.WithTimeout(ctx, time.Second*5)
+
+		u.Intercept(reqCtx, &Request{
+			ctx:    reqCtx,
+			cancel: reqCancel,
+			w:      w,
+			r:      req,
+		})
+		reqCancel()
+
+		if w.response == nil {
+			t.Fatalf("expected response for %s", domain)
+		}
+
+		if w.response.Id != req.Id {
+			t.Fatalf("expected id %d, got %d", req.Id, w.response.Id)
+		}
+
+		if len(w.response.Answer) != 1 ||
+			w.response.Answer[0].Header().Name != domain {
+			t.Fatalf("unexpected answer %v", w.response.Answer)
+		}
+	}
+}
+
+func Test_DoQListener_MissingTLS(t *testing.T) {
+	_, err := DoQListener(
+		&NOOPLogger{},
+		"127.0.0.1:0",
+		dns.DefaultServeMux,
+		nil,
+		nil,
+	)
+	if err == nil {
+		t.Fatal("expected error for missing tls configuration")
+	}
+}
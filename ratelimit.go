@@ -0,0 +1,444 @@
+package void
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	defaultSlip       = 2
+	defaultIPv4Prefix = 24
+	defaultIPv6Prefix = 56
+
+	// rateLimitInterval is how often idle buckets are cleaned up and the
+	// number of limited requests is logged.
+	rateLimitInterval = time.Minute
+
+	// The actions of the rate limits, reported in the trace of limited
+	// responses.
+	limitRefused = "refused"
+	limitDropped = "dropped"
+	limitSlipped = "slipped"
+)
+
+// RateLimitConfig configures the per client rate limits. A rate of zero
+// disables the associated limit.
+type RateLimitConfig struct {
+	// QPS is the queries per second allowed from a single client IP
+	QPS float64
+
+	// Subnet is the queries per second allowed from all clients in the
+	// same subnet (see IPv4 and IPv6)
+	Subnet float64
+
+	// Burst is the number of queries allowed above the rate limits,
+	// defaults to the rate
+	Burst int
+
+	// Responses is the identical responses per second (same name, type,
+	// and rcode) allowed to a single subnet over UDP, limiting the use of
+	// void as an amplification source (Response Rate Limiting)
+	Responses float64
+
+	// Slip is the interval of responses dropped by the response rate
+	// limit which are instead answered with an empty truncated response
+	// so that legitimate clients retry over TCP. Zero drops all responses
+	// and one truncates all responses, defaults to 2.
+	Slip *int
+
+	// IPv4 and IPv6 are the prefix lengths used to group clients into
+	// subnets, defaults to /24 and /56.
+	IPv4 int
+	IPv6 int
+}
+
+// Enabled indicates if any limit is configured.
+func (c RateLimitConfig) Enabled() bool {
+	return c.QPS > 0 || c.Subnet > 0 || c.Responses > 0
+}
+
+// RateLimiter creates the rate limiter for client requests which wraps
+// the handler of the dns server.
+func RateLimiter(
+	ctx context.Context,
+	logger Logger,
+	cfg RateLimitConfig,
+) (*Limiter, error) {
+	err := checkNil(ctx, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.QPS < 0 || cfg.Subnet < 0 || cfg.Responses < 0 || cfg.Burst < 0 {
+		return nil, fmt.Errorf("invalid rate limit: negative rate")
+	}
+
+	if cfg.IPv4 == 0 {
+		cfg.IPv4 = defaultIPv4Prefix
+	}
+
+	if cfg.IPv6 == 0 {
+		cfg.IPv6 = defaultIPv6Prefix
+	}
+
+	if cfg.IPv4 < 0 || cfg.IPv4 > 32 || cfg.IPv6 < 0 || cfg.IPv6 > 128 {
+		return nil, fmt.Errorf(
+			"invalid rate limit prefix: ipv4 /%d, ipv6 /%d",
+			cfg.IPv4,
+			cfg.IPv6,
+		)
+	}
+
+	slip := defaultSlip
+	if cfg.Slip != nil {
+		slip = *cfg.Slip
+	}
+
+	if slip < 0 {
+		return nil, fmt.Errorf("invalid rate limit slip: %d", slip)
+	}
+
+	l := &Limiter{
+		ctx:       ctx,
+		logger:    logger,
+		ipv4:      cfg.IPv4,
+		ipv6:      cfg.IPv6,
+		slip:      uint64(slip),
+		clients:   newBuckets(cfg.QPS, cfg.Burst),
+		subnets:   newBuckets(cfg.Subnet, cfg.Burst),
+		responses: newBuckets(cfg.Responses, cfg.Burst),
+	}
+
+	go l.cleanup(rateLimitInterval)
+
+	return l, nil
+}
+
+// Limiter enforces the per client and per subnet query rate limits, and
+// the response rate limit for UDP clients.
+type Limiter struct {
+	ctx    context.Context
+	logger Logger
+	ipv4   int
+	ipv6   int
+	slip   uint64
+
+	// metrics emits the trace of the requests refused by the limiter,
+	// which are not passed to the handler
+	metrics bool
+
+	clients   *buckets
+	subnets   *buckets
+	responses *buckets
+
+	// limited is the total number of responses limited by the response
+	// rate limit which determines the responses that slip
+	limited atomic.Uint64
+
+	refused atomic.Uint64
+	dropped atomic.Uint64
+	slipped atomic.Uint64
+}
+
+// Limit wraps the handler enforcing the rate limits. Clients exceeding
+// the query rate are answered with REFUSED. Responses exceeding the
+// response rate are dropped, or truncated based on the slip.
+func (l *Limiter) Limit(next HandleFunc) HandleFunc {
+	return func(w dns.ResponseWriter, req *dns.Msg) {
+		ip, ok := clientIP(w.RemoteAddr())
+		if !ok {
+			next(w, req)
+			return
+		}
+
+		subnet := l.subnet(ip)
+
+		if !l.clients.allow(ip.String()) || !l.subnets.allow(subnet.String()) {
+			l.refused.Add(1)
+			l.logger.Debugw(
+				"rate limited",
+				"category", RATELIMIT,
+				"action", limitRefused,
+				"client", ip.String(),
+				"subnet", subnet.String(),
+			)
+
+			start := time.Now()
+			res := (&dns.Msg{}).SetRcode(req, dns.RcodeRefused)
+
+			err := w.WriteMsg(res)
+			if l.metrics {
+				l.trace(w, res, start)
+			}
+
+			if err != nil {
+				l.logger.Errorw(
+					"failed to refuse request",
+					"category", RATELIMIT,
+					"client", ip.String(),
+					"error", err,
+				)
+			}
+
+			return
+		}
+
+		// Response rate limiting only applies to datagram transports
+		// where the source address can be spoofed
+		if l.responses.rate > 0 && truncates(w) {
+			w = &rrlWriter{ResponseWriter: w, limiter: l, subnet: subnet}
+		}
+
+		next(w, req)
+	}
+}
+
+// trace emits the trace of a request refused by the limiter in the same
+// way as the metric writer, as the request never enters the pipeline.
+func (l *Limiter) trace(w dns.ResponseWriter, res *dns.Msg, start time.Time) {
+	elapsed := time.Since(start)
+
+	t := &Trace{
+		ID:       requestID.Add(1),
+		Client:   w.RemoteAddr().String(),
+		Server:   w.LocalAddr().String(),
+		Rcode:    dns.RcodeToString[res.Rcode],
+		Limited:  limitRefused,
+		Duration: elapsed,
+		Stages: []Span{{
+			Stage:    RATELIMIT,
+			Exit:     elapsed,
+			Decision: ANSWER,
+		}},
+	}
+
+	t.question(res)
+
+	l.logger.Debugw(
+		"wrote response",
+		"id", t.ID,
+		"duration", t.Duration,
+		"trace", t,
+	)
+}
+
+// subnet returns the prefix of the client used to group clients.
+func (l *Limiter) subnet(ip netip.Addr) netip.Prefix {
+	bits := l.ipv6
+	if ip.Is4() {
+		bits = l.ipv4
+	}
+
+	prefix, err := ip.Prefix(bits)
+	if err != nil {
+		return netip.PrefixFrom(ip, ip.BitLen())
+	}
+
+	return prefix
+}
+
+// cleanup periodically removes idle buckets and logs the number of
+// limited requests in the interval.
+func (l *Limiter) cleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.ctx.Done():
+			return
+		case <-ticker.C:
+			l.clients.prune()
+			l.subnets.prune()
+			l.responses.prune()
+
+			refused := l.refused.Swap(0)
+			dropped := l.dropped.Swap(0)
+			slipped := l.slipped.Swap(0)
+			if refused+dropped+slipped == 0 {
+				continue
+			}
+
+			l.logger.Infow(
+				"rate limited",
+				"category", RATELIMIT,
+				"interval", interval,
+				"refused", refused,
+				"dropped", dropped,
+				"slipped", slipped,
+			)
+		}
+	}
+}
+
+// limitedWriter is a writer which may limit the response, reporting the
+// action of the rate limit for the trace of the request.
+type limitedWriter interface {
+	limited() string
+}
+
+// rrlWriter limits the rate of identical responses to a subnet.
+type rrlWriter struct {
+	dns.ResponseWriter
+	limiter *Limiter
+	subnet  netip.Prefix
+
+	// action is the rate limit action of the written response
+	action string
+}
+
+func (r *rrlWriter) limited() string {
+	return r.action
+}
+
+func (r *rrlWriter) WriteMsg(res *dns.Msg) error {
+	key := r.subnet.String()
+	if len(res.Question) > 0 {
+		key = fmt.Sprintf(
+			"%s:%s:%d:%d",
+			key,
+			res.Question[0].Name,
+			res.Question[0].Qtype,
+			res.Rcode,
+		)
+	}
+
+	if r.limiter.responses.allow(key) {
+		return r.ResponseWriter.WriteMsg(res)
+	}
+
+	n := r.limiter.limited.Add(1)
+	if r.limiter.slip == 0 || n%r.limiter.slip != 0 {
+		r.action = limitDropped
+		r.limiter.dropped.Add(1)
+		r.limiter.logger.Debugw(
+			"rate limited",
+			"category", RATELIMIT,
+			"action", limitDropped,
+			"subnet", r.subnet.String(),
+		)
+
+		return nil
+	}
+
+	r.action = limitSlipped
+	r.limiter.slipped.Add(1)
+	r.limiter.logger.Debugw(
+		"rate limited",
+		"category", RATELIMIT,
+		"action", limitSlipped,
+		"subnet", r.subnet.String(),
+	)
+
+	tc := &dns.Msg{}
+	tc.SetReply(res)
+	tc.Rcode = res.Rcode
+	tc.Truncated = true
+
+	return r.ResponseWriter.WriteMsg(tc)
+}
+
+// clientIP returns the IP address of the client.
+func clientIP(addr net.Addr) (netip.Addr, bool) {
+	var ip net.IP
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		ip = a.IP
+	case *net.TCPAddr:
+		ip = a.IP
+	default:
+		return netip.Addr{}, false
+	}
+
+	parsed, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return netip.Addr{}, false
+	}
+
+	return parsed.Unmap(), true
+}
+
+// bucket is a token bucket refilled at the rate of the buckets.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// buckets tracks a token bucket per key, where a rate of zero allows
+// all requests.
+type buckets struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+func newBuckets(rate float64, burst int) *buckets {
+	b := float64(burst)
+	if b == 0 {
+		b = math.Max(rate, 1)
+	}
+
+	return &buckets{
+		rate:    rate,
+		burst:   b,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// allow consumes a token for the key returning false when the bucket
+// for the key is empty.
+func (b *buckets) allow(key string) bool {
+	if b.rate <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	bk, ok := b.buckets[key]
+	if !ok {
+		bk = &bucket{tokens: b.burst, last: now}
+		b.buckets[key] = bk
+	}
+
+	bk.tokens = math.Min(b.burst, bk.tokens+now.Sub(bk.last).Seconds()*b.rate)
+	bk.last = now
+
+	if bk.tokens < 1 {
+		return false
+	}
+
+	bk.tokens--
+	return true
+}
+
+// prune removes the buckets which have refilled completely since they
+// are equivalent to a new bucket.
+func (b *buckets) prune() {
+	if b.rate <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	for key, bk := range b.buckets {
+		if bk.tokens+now.Sub(bk.last).Seconds()*b.rate >= b.burst {
+			delete(b.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package void
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+type remoteWriter struct {
+	captureWriter
+	remote net.Addr
+}
+
+func (r *remoteWriter) RemoteAddr() net.Addr { return r.remote }
+
+func answerHandler(w dns.ResponseWriter, req *dns.Msg) {
+	_ = w.WriteMsg((&dns.Msg{}).SetReply(req))
+}
+
+func Test_buckets(t *testing.T) {
+	now := time.Now()
+	b := newBuckets(2, 0)
+	b.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if !b.allow("client") {
+			t.Fatalf("expected request %d to be allowed", i)
+		}
+	}
+
+	if b.allow("client") {
+		t.Fatal("expected request to be limited")
+	}
+
+	if !b.allow("other") {
+		t.Fatal("expected other key to be allowed")
+	}
+
+	now = now.Add(time.Millisecond * 500)
+	if !b.allow("client") {
+		t.Fatal("expected request to be allowed after refill")
+	}
+
+	if b.allow("client") {
+		t.Fatal("expected request to be limited")
+	}
+
+	now = now.Add(time.Second * 10)
+	b.prune()
+
+	if len(b.buckets) != 0 {
+		t.Fatalf("expected idle buckets to be pruned, got %d", len(b.buckets))
+	}
+}
+
+func Test_Limiter_Limit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	one := 1
+	tests := map[string]struct {
+		cfg      RateLimitConfig
+		remotes  []string
+		udp      bool
+		requests int
+		expected []int // rcode per request, -1 is dropped
+		tc       []bool
+	}{
+		"disabled": {
+			remotes:  []string{"192.168.0.10"},
+			requests: 3,
+			expected: []int{0, 0, 0},
+		},
+		"client-qps": {
+			cfg:      RateLimitConfig{QPS: 2},
+			remotes:  []string{"192.168.0.10"},
+			requests: 3,
+			expected: []int{0, 0, dns.RcodeRefused},
+		},
+		"client-qps-separate-clients": {
+			cfg:      RateLimitConfig{QPS: 1},
+			remotes:  []string{"192.168.0.10", "192.168.0.11"},
+			requests: 2,
+			expected: []int{0, 0},
+		},
+		"subnet-qps": {
+			cfg:      RateLimitConfig{Subnet: 1},
+			remotes:  []string{"192.168.0.10", "192.168.0.11"},
+			requests: 2,
+			expected: []int{0, dns.RcodeRefused},
+		},
+		"subnet-qps-ipv6": {
+			cfg:      RateLimitConfig{Subnet: 1},
+			remotes:  []string{"2001:db8::1", "2001:db8:0:ff::1"},
+			requests: 2,
+			expected: []int{0, dns.RcodeRefused},
+		},
+		"rrl-slip": {
+			cfg:      RateLimitConfig{Responses: 1},
+			remotes:  []string{"192.168.0.10"},
+			udp:      true,
+			requests: 4,
+			expected: []int{0, -1, 0, -1},
+			tc:       []bool{false, false, true, false},
+		},
+		"rrl-no-slip": {
+			cfg:      RateLimitConfig{Responses: 1, Slip: new(int)},
+			remotes:  []string{"192.168.0.10"},
+			udp:      true,
+			requests: 3,
+			expected: []int{0, -1, -1},
+		},
+		"rrl-slip-all": {
+			cfg:      RateLimitConfig{Responses: 1, Slip: &one},
+			remotes:  []string{"192.168.0.10"},
+			udp:      true,
+			requests: 3,
+			expected: []int{0, 0, 0},
+			tc:       []bool{false, true, true},
+		},
+		"rrl-tcp": {
+			cfg:      RateLimitConfig{Responses: 1},
+			remotes:  []string{"192.168.0.10"},
+			requests: 3,
+			expected: []int{0, 0, 0},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			l, err := RateLimiter(ctx, &NOOPLogger{}, test.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			handler := l.Limit(answerHandler)
+
+			for i := 0; i < test.requests; i++ {
+				remote := test.remotes[i%len(test.remotes)]
+				req := Question(t, "test.example.tld.", dns.TypeA)
+
+				capture := &remoteWriter{
+					remote: &net.UDPAddr{IP: net.ParseIP(remote), Port: 5353},
+				}
+
+				var w dns.ResponseWriter = capture
+				if test.udp {
+					w = &ednsWriter{capture, req, udpSize(req)}
+				}
+
+				handler(w, req)
+
+				if test.expected[i] < 0 {
+					if capture.response != nil {
+						t.Fatalf("request %d: expected response to be dropped", i)
+					}
+
+					continue
+				}
+
+				if capture.response == nil {
+					t.Fatalf("request %d: expected response", i)
+				}
+
+				if capture.response.Rcode != test.expected[i] {
+					t.Fatalf(
+						"request %d: expected rcode %s, got %s",
+						i,
+						dns.RcodeToString[test.expected[i]],
+						dns.RcodeToString[capture.response.Rcode],
+					)
+				}
+
+				tc := test.tc != nil && test.tc[i]
+				if capture.response.Truncated != tc {
+					t.Fatalf("request %d: expected truncated %v", i, tc)
+				}
+			}
+		})
+	}
+}
+
+func Test_RateLimiter_Invalid(t *testing.T) {
+	negative := -1
+	tests := map[string]RateLimitConfig{
+		"negative-qps":  {QPS: -1},
+		"negative-slip": {Responses: 1, Slip: &negative},
+		"ipv4-prefix":   {QPS: 1, IPv4: 33},
+		"ipv6-prefix":   {QPS: 1, IPv6: 129},
+	}
+
+	for name, cfg := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := RateLimiter(context.Background(), &NOOPLogger{}, cfg)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+type addrWriter struct {
+	remoteWriter
+	local net.Addr
+}
+
+func (a *addrWriter) LocalAddr() net.Addr { return a.local }
+
+func Test_Limiter_Trace(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logger := &traceLogger{traces: make(chan *Trace, 1)}
+
+	l, err := RateLimiter(ctx, logger, RateLimitConfig{QPS: 3, Responses: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l.metrics = true
+
+	handler, requests := Convert(ctx, logger, true)
+	go func() {
+		for req := range requests {
+			_ = req.Answer((&dns.Msg{}).SetReply(req.r))
+		}
+	}()
+
+	handler = l.Limit(handler)
+
+	// The response rate limit drops and then slips the identical
+	// responses, until the query rate limit refuses the client
+	expected := []string{"", limitDropped, limitSlipped, limitRefused}
+
+	for i, action := range expected {
+		req := Question(t, "test.example.tld.", dns.TypeA)
+		capture := &addrWriter{
+			remoteWriter: remoteWriter{
+				remote: &net.UDPAddr{IP: net.ParseIP("192.168.0.10"), Port: 5353},
+			},
+			local: &net.UDPAddr{IP: net.ParseIP("192.168.0.2"), Port: 53},
+		}
+
+		handler(&ednsWriter{capture, req, udpSize(req)}, req)
+
+		select {
+		case trace := <-logger.traces:
+			if trace.Limited != action {
+				t.Fatalf(
+					"request %d: expected limited [%s], got [%s]",
+					i,
+					action,
+					trace.Limited,
+				)
+			}
+
+			if trace.Name != "test.example.tld." {
+				t.Fatalf("request %d: unexpected trace %s", i, trace)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("request %d: expected trace", i)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package void
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// RebindAction is the response to upstream answers with private
+// addresses.
+type RebindAction string
+
+const (
+	// REBINDSTRIP removes the private addresses from the answers.
+	REBINDSTRIP RebindAction = "strip"
+
+	// REBINDREFUSE answers the request with REFUSED.
+	REBINDREFUSE RebindAction = "refuse"
+)
+
+// RebindConfig configures the DNS rebinding protection which guards the
+// clients on the local network from public names resolving to private,
+// loopback, or link-local addresses.
+type RebindConfig struct {
+	// Action is either strip or refuse, defaults to strip.
+	Action RebindAction
+
+	// Allow are the domains which may resolve to private addresses, where
+	// patterns starting with * are wildcards (e.g. *.home.arpa). The names
+	// of the local records are always allowed.
+	Allow []string
+}
+
+// RebindGuard creates the DNS rebinding protection for upstream responses,
+// where the names of the local records and the allowed domains may resolve
+// to private addresses.
+func RebindGuard(
+	ctx context.Context,
+	logger Logger,
+	cfg RebindConfig,
+	local ...*Record,
+) (*Rebind, error) {
+	err := checkNil(ctx, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Action {
+	case "":
+		cfg.Action = REBINDSTRIP
+	case REBINDSTRIP, REBINDREFUSE:
+	default:
+		return nil, fmt.Errorf("invalid rebind action [%s]", cfg.Action)
+	}
+
+	records := make([]*Record, 0, len(local)+len(cfg.Allow))
+	records = append(records, local...)
+
+	for _, domain := range cfg.Allow {
+		domain = strings.TrimSuffix(domain, ".")
+
+		tpe := DIRECT
+		if strings.HasPrefix(domain, "*") {
+			tpe = WILDCARD
+		}
+
+		records = append(records, &Record{
+			Pattern:  domain,
+			Type:     tpe,
+			Category: string(REBIND),
+		})
+	}
+
+	m, err := NewMatcher(ctx, logger, records...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Rebind{
+		Matcher: m,
+		ctx:     ctx,
+		logger:  logger,
+		action:  cfg.Action,
+	}, nil
+}
+
+// Rebind guards against DNS rebinding by inspecting the upstream
+// responses for private addresses.
+type Rebind struct {
+	*Matcher
+	ctx    context.Context
+	logger Logger
+	action RebindAction
+}
+
+// Intercept wraps the writer of the request with a rebindWriter, which
+// inspects the response on the way back to the client, unless the name is
+// allowed to resolve to private addresses.
+func (r *Rebind) Intercept(
+	ctx context.Context,
+	req *Request,
+) (*Request, bool) {
+	if r.Match(ctx, req.Record()) != nil {
+		return req, true
+	}
+
+	req.w = &rebindWriter{
+		rebind: r,
+		req:    req,
+		next:   req.w.WriteMsg,
+	}
+
+	return req, true
+}
+
+// rebindWriter is a Writer which strips or refuses responses with private
+// addresses in the answers.
+type rebindWriter struct {
+	rebind *Rebind
+	req    *Request
+	next   func(*dns.Msg) error
+}
+
+func (w *rebindWriter) WriteMsg(res *dns.Msg) error {
+	if res == nil {
+		return w.next(res)
+	}
+
+	kept := make([]dns.RR, 0, len(res.Answer))
+	for _, rr := range res.Answer {
+		if !private(rr) {
+			kept = append(kept, rr)
+		}
+	}
+
+	if len(kept) == len(res.Answer) {
+		return w.next(res)
+	}
+
+	w.rebind.logger.Infow(
+		"rebinding",
+		"category", REBIND,
+		"event", &Event{
+			Msg:      "rebinding",
+			Name:     w.req.r.Question[0].Name,
+			Type:     dns.Type(w.req.r.Question[0].Qtype),
+			Client:   w.req.client,
+			Server:   w.req.server,
+			Category: REBIND,
+		},
+		"action", w.rebind.action,
+		"stripped", len(res.Answer)-len(kept),
+	)
+
+	if w.rebind.action == REBINDREFUSE {
+		return w.next((&dns.Msg{}).SetRcode(w.req.r, dns.RcodeRefused))
+	}
+
+	stripped := res.Copy()
+	stripped.Answer = kept
+
+	return w.next(stripped)
+}
+
+// private indicates if the record is an address on a private network,
+// including the loopback, link-local, and unspecified addresses.
+func private(rr dns.RR) bool {
+	var ip netip.Addr
+	switch v := rr.(type) {
+	case *dns.A:
+		ip, _ = netip.AddrFromSlice(v.A)
+	case *dns.AAAA:
+		ip, _ = netip.AddrFromSlice(v.AAAA)
+	default:
+		return false
+	}
+
+	ip = ip.Unmap()
+
+	return ip.IsPrivate() ||
+		ip.IsLoopback() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsUnspecified()
+}
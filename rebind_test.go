@@ -0,0 +1,148 @@
+package void
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func Test_Rebind(t *testing.T) {
+	pctx, pcancel := context.WithCancel(context.Background())
+	defer pcancel()
+
+	local := &Record{
+		Pattern: "nas.lan",
+		Type:    DIRECT,
+		IP:      net.ParseIP("192.168.0.10"),
+	}
+
+	addr := func(name, ip string) dns.RR {
+		rr, err := dns.NewRR(name + " 60 IN A " + ip)
+		if parsed := net.ParseIP(ip); parsed.To4() == nil {
+			rr, err = dns.NewRR(name + " 60 IN AAAA " + ip)
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return rr
+	}
+
+	tests := map[string]struct {
+		action   RebindAction
+		name     string
+		answer   []string
+		expected int
+		rcode    int
+	}{
+		"public": {
+			name:     "example.tld.",
+			answer:   []string{"93.184.216.34"},
+			expected: 1,
+		},
+		"private": {
+			name:   "evil.example.tld.",
+			answer: []string{"192.168.0.1"},
+		},
+		"loopback": {
+			name:   "evil.example.tld.",
+			answer: []string{"127.0.0.1"},
+		},
+		"link-local-ipv6": {
+			name:   "evil.example.tld.",
+			answer: []string{"fe80::1"},
+		},
+		"unique-local-ipv6": {
+			name:   "evil.example.tld.",
+			answer: []string{"fd00::1"},
+		},
+		"mixed": {
+			name:     "evil.example.tld.",
+			answer:   []string{"10.0.0.1", "93.184.216.34"},
+			expected: 1,
+		},
+		"refuse": {
+			action: REBINDREFUSE,
+			name:   "evil.example.tld.",
+			answer: []string{"10.0.0.1", "93.184.216.34"},
+			rcode:  dns.RcodeRefused,
+		},
+		"local": {
+			name:     "nas.lan.",
+			answer:   []string{"192.168.0.10"},
+			expected: 1,
+		},
+		"allowed": {
+			name:     "router.home.arpa.",
+			answer:   []string{"192.168.0.1"},
+			expected: 1,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(pctx)
+			defer cancel()
+
+			rebind, err := RebindGuard(pctx, &NOOPLogger{}, RebindConfig{
+				Action: test.action,
+				Allow:  []string{"*.home.arpa"},
+			}, local)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w := &TestWriter{}
+			req := &Request{
+				ctx:    ctx,
+				cancel: cancel,
+				w:      w,
+				r:      Question(t, test.name, dns.TypeA),
+			}
+
+			req, pass := rebind.Intercept(ctx, req)
+			if !pass {
+				t.Fatal("expected request to pass")
+			}
+
+			res := (&dns.Msg{}).SetReply(req.r)
+			for _, ip := range test.answer {
+				res.Answer = append(res.Answer, addr(test.name, ip))
+			}
+
+			err = req.Answer(res)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if w.response.Rcode != test.rcode {
+				t.Fatalf(
+					"expected rcode %s, got %s",
+					dns.RcodeToString[test.rcode],
+					dns.RcodeToString[w.response.Rcode],
+				)
+			}
+
+			if len(w.response.Answer) != test.expected {
+				t.Fatalf(
+					"expected %d answers, got %v",
+					test.expected,
+					w.response.Answer,
+				)
+			}
+		})
+	}
+}
+
+func Test_RebindGuard_Action(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, err := RebindGuard(ctx, &NOOPLogger{}, RebindConfig{Action: "drop"})
+	if err == nil {
+		t.Fatal("expected error for invalid action")
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package void
 
 import (
 	"context"
@@ -98,11 +98,40 @@ func (m *Matcher) Match(ctx context.Context, domain string) *Record {
 type Record struct {
 	Pattern  string
 	Type     Type
+	Action   Action
 	IP       net.IP
 	Category string
 	Tags     []string
 	Source   string
 	Comment  string
+
+	// IPv6 is the IPv6 address of a record which redirects to both an
+	// IPv4 address, in IP, and an IPv6 address.
+	IPv6 net.IP
+
+	// Schedule limits when the record is applied, see Schedules
+	// for the schedules of categories.
+	Schedule *Schedule
+
+	// Mode is the block mode of the source of the record, which is
+	// overridden by the Action of the record.
+	Mode *BlockMode
+}
+
+// validate validates the schedule and block mode of the record.
+func (r *Record) validate() error {
+	if r.Schedule != nil {
+		err := r.Schedule.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	if r.Mode != nil {
+		return r.Mode.Validate()
+	}
+
+	return nil
 }
 
 func (r *Record) String() string {
@@ -125,10 +154,17 @@ func (r *Record) String() string {
 
 // MarshalJSON implements the json.Marshaler interface.
 func (r *Record) MarshalJSON() ([]byte, error) {
+	var ipv6 string
+	if r.IPv6 != nil {
+		ipv6 = r.IPv6.String()
+	}
+
 	d := struct {
 		Domain   string   `json:"domain"`
 		Type     string   `json:"type,omitempty"`
+		Action   string   `json:"action,omitempty"`
 		IP       string   `json:"ip,omitempty"`
+		IPv6     string   `json:"ipv6,omitempty"`
 		Category string   `json:"category,omitempty"`
 		Tags     []string `json:"tags,omitempty"`
 		Source   string   `json:"source,omitempty"`
@@ -136,7 +172,9 @@ func (r *Record) MarshalJSON() ([]byte, error) {
 	}{
 		Domain:   r.Pattern,
 		Type:     r.Type.String(),
+		Action:   r.Action.String(),
 		IP:       r.IP.String(),
+		IPv6:     ipv6,
 		Category: r.Category,
 		Tags:     r.Tags,
 		Source:   r.Source,
@@ -151,7 +189,9 @@ func (r *Record) UnmarshalJSON(data []byte) error {
 	d := struct {
 		Domain   string   `json:"domain"`
 		Type     string   `json:"type"`
+		Action   string   `json:"action"`
 		IP       string   `json:"ip"`
+		IPv6     string   `json:"ipv6"`
 		Category string   `json:"category"`
 		Tags     []string `json:"tags"`
 		Source   string   `json:"source"`
@@ -164,7 +204,9 @@ func (r *Record) UnmarshalJSON(data []byte) error {
 
 	r.Pattern = d.Domain
 	r.Type = Type(d.Type)
+	r.Action = Action(d.Action)
 	r.IP = net.ParseIP(d.IP)
+	r.IPv6 = net.ParseIP(d.IPv6)
 	r.Category = d.Category
 	r.Tags = d.Tags
 	r.Source = d.Source
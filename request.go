@@ -1,20 +1,48 @@
-package main
+package void
 
 import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/miekg/dns"
 )
 
-// TODO: Setup initializer for request, move to interface? etc...
-// Add invalidation for the *dns.Msg in the initializer
+// DEFAULTTTL defines the default ttl for records that either do not
+// provide a TTL, are blocked, or are local records.
+const DEFAULTTTL = 3600
 
+// Writer writes the response to a request.
 type Writer interface {
 	WriteMsg(res *dns.Msg) error
 }
 
+// NewRequest creates a request for evaluation in the pipeline, where the
+// response is written to w. The server and client are the addresses of
+// the listener and the client which sent the request.
+func NewRequest(
+	ctx context.Context,
+	w Writer,
+	msg *dns.Msg,
+	server, client string,
+) *Request {
+	ctx, cancel := context.WithCancel(ctx)
+
+	return &Request{
+		ctx:    ctx,
+		cancel: cancel,
+		w:      w,
+		r:      msg,
+		server: server,
+		client: client,
+		id:     requestID.Add(1),
+		start:  time.Now(),
+	}
+}
+
 // Request encapsulates all of the request
 // data for evaluation in the pipeline.
 type Request struct {
@@ -25,6 +53,56 @@ type Request struct {
 	record string
 	server string
 	client string
+
+	// network is the transport of the listener the request was received
+	// on, either udp or tcp
+	network string
+
+	// group is the client group selected for the request
+	group string
+
+	// answered ensures a single response is written when multiple
+	// upstreams answer at the same time
+	answered atomic.Bool
+
+	// nocache skips caching the response of a request which depends on
+	// the state of blocking, such as blocked or paused requests, so that
+	// pausing and resuming apply to the next request
+	nocache bool
+
+	// id and start identify and time the request for its trace
+	// of the spans of the pipeline stages
+	id    uint64
+	start time.Time
+	mu    sync.Mutex
+	spans []Span
+}
+
+// Context returns the context of the request which is canceled once the
+// request is answered.
+func (r *Request) Context() context.Context {
+	return r.ctx
+}
+
+// Msg returns the DNS request message.
+func (r *Request) Msg() *dns.Msg {
+	return r.r
+}
+
+// Client returns the address of the client which sent the request.
+func (r *Request) Client() string {
+	return r.client
+}
+
+// Server returns the address of the listener which received the request.
+func (r *Request) Server() string {
+	return r.server
+}
+
+// Group returns the name of the client group of the request, which is
+// empty when the client does not belong to a group.
+func (r *Request) Group() string {
+	return r.group
 }
 
 // Record returns the requested domain.
@@ -37,12 +115,18 @@ func (r *Request) Record() string {
 }
 
 // Key returns a unique identifier for the request which is an aggregate
-// of the name, type, and class.
+// of the name, type, and class. The key is prefixed with the client group
+// since the responses of one group may be blocked for another.
 func (r *Request) Key() string {
 	// TODO: Add validation?
 	q := r.r.Question[0]
 
-	return fmt.Sprintf("%s:%d:%d", q.Name, q.Qtype, q.Qclass)
+	key := fmt.Sprintf("%s:%d:%d", q.Name, q.Qtype, q.Qclass)
+	if r.group != "" {
+		key = fmt.Sprintf("%s:%s", r.group, key)
+	}
+
+	return key
 }
 
 func (r *Request) String() string {
@@ -54,31 +138,60 @@ func (r *Request) String() string {
 	)
 }
 
-// Block writes a block response to the request
-// directly to the original response writer.
-func (r *Request) Block() error {
-	select {
-	case <-r.ctx.Done():
-		return r.ctx.Err()
-	default:
-		r.cancel()
+// Block writes the block response of the mode to the request directly
+// to the original response writer, where the zero mode is NXDOMAIN.
+func (r *Request) Block(mode BlockMode) error {
+	err := r.answer()
+	if err != nil {
+		return err
+	}
 
-		// Send to the void
-		return r.w.WriteMsg(
-			(&dns.Msg{}).SetRcode(r.r, dns.RcodeNameError),
-		)
+	// Send to the void
+	return r.w.WriteMsg(mode.response(r.r))
+}
+
+// Fail writes a response with the provided rcode, such as FORMERR or
+// NOTIMP, directly to the original response writer.
+func (r *Request) Fail(rcode int) error {
+	err := r.answer()
+	if err != nil {
+		return err
 	}
+
+	return r.w.WriteMsg((&dns.Msg{}).SetRcode(r.r, rcode))
+}
+
+// Drop cancels the request without writing a response so that
+// the client times out.
+func (r *Request) Drop() {
+	r.cancel()
 }
 
 // Answer returns a response for a specific domain request with the
 // provided IP address.
 func (r *Request) Answer(msg *dns.Msg) error {
+	err := r.answer()
+	if err != nil {
+		return err
+	}
+
+	return r.w.WriteMsg(msg)
+}
+
+// answer claims the response of the request and cancels the request,
+// returning context.Canceled when the request was already answered.
+func (r *Request) answer() error {
 	select {
 	case <-r.ctx.Done():
 		return r.ctx.Err()
 	default:
-		r.cancel()
+	}
 
-		return r.w.WriteMsg(msg)
+	if !r.answered.CompareAndSwap(false, true) {
+		return context.Canceled
 	}
+
+	r.cancel()
+
+	return nil
 }
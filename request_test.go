@@ -0,0 +1,57 @@
+package void
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// countWriter counts the responses written.
+type countWriter struct {
+	writes atomic.Int32
+}
+
+func (w *countWriter) WriteMsg(*dns.Msg) error {
+	w.writes.Add(1)
+	return nil
+}
+
+func Test_Request_Answer_Once(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for i := 0; i < 100; i++ {
+		w := &countWriter{}
+		req := NewRequest(ctx, w, Question(t, "example.tld.", dns.TypeA), "", "")
+
+		// Concurrent upstreams answering the same request
+		var wg sync.WaitGroup
+		start := make(chan struct{})
+		for j := 0; j < 4; j++ {
+			wg.Add(1)
+			go func(j int) {
+				defer wg.Done()
+				<-start
+
+				switch j {
+				case 0:
+					_ = req.Fail(dns.RcodeServerFailure)
+				case 1:
+					_ = req.Block(BlockMode{})
+				default:
+					_ = req.Answer((&dns.Msg{}).SetReply(req.r))
+				}
+			}(j)
+		}
+
+		close(start)
+		wg.Wait()
+
+		if n := w.writes.Load(); n != 1 {
+			t.Fatalf("expected 1 write, got %d", n)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package void
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// rpzTriggers are the labels of the RPZ trigger types other than QNAME,
+// which match on the response or client rather than the requested name.
+//
+//nolint:gochecknoglobals // constant lookup
+var rpzTriggers = []string{
+	"rpz-ip",
+	"rpz-nsip",
+	"rpz-nsdname",
+	"rpz-client-ip",
+}
+
+// parseRPZ parses the QNAME triggers of a Response Policy Zone file into
+// hosts with the action of each policy.
+//
+// https://datatracker.ietf.org/doc/html/draft-vixie-dnsop-dns-rpz
+//
+// Supported policies:
+//
+//	bad.example.com     CNAME .      ; NXDOMAIN
+//	*.bad.example.com   CNAME *.     ; NODATA
+//	ads.example.com     A     0.0.0.0 ; local-data
+//	ads.example.com     AAAA  ::      ; local-data
+//
+// The owner names are relative to the zone, taken from the SOA record,
+// and wildcard owners are converted to WILDCARD hosts. The A and AAAA
+// local-data of an owner are merged into a single host redirecting each
+// type to its address.
+//
+// Other policies, such as rpz-passthru, rpz-drop, CNAME rewrites to other
+// domains, and the IP triggers, are not supported and are logged and
+// skipped.
+func parseRPZ(ctx context.Context, logger Logger, data []byte) Hosts {
+	hosts := Hosts{}
+
+	// redirects are the local-data hosts by owner, which the local-data
+	// of the other address type is merged into
+	redirects := make(map[string]*Host)
+
+	var zone string
+	zp := dns.NewZoneParser(bytes.NewReader(data), ".", "")
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		select {
+		case <-ctx.Done():
+			return hosts
+		default:
+		}
+
+		if soa, ok := rr.(*dns.SOA); ok {
+			zone = soa.Hdr.Name
+			continue
+		}
+
+		if rr.Header().Rrtype == dns.TypeNS {
+			continue
+		}
+
+		host := rpzHost(zone, rr)
+		if host == nil {
+			logger.Debugw(
+				"unsupported rpz policy",
+				"record", rr.String(),
+			)
+
+			continue
+		}
+
+		if host.Action == REDIRECT {
+			if h, ok := redirects[host.Domain]; ok {
+				h.merge(host.IP)
+				continue
+			}
+
+			redirects[host.Domain] = host
+		}
+
+		hosts = append(hosts, host)
+	}
+
+	if err := zp.Err(); err != nil {
+		logger.Errorw(
+			"failed to parse rpz",
+			"zone", zone,
+			"error", err,
+		)
+	}
+
+	return hosts
+}
+
+// rpzHost converts the policy record to a host, returning nil for
+// unsupported triggers and actions.
+func rpzHost(zone string, rr dns.RR) *Host {
+	name := rr.Header().Name
+	if zone != "." && dns.IsSubDomain(zone, name) {
+		name = strings.TrimSuffix(name, zone)
+	}
+
+	name = strings.TrimSuffix(name, ".")
+	if name == "" {
+		return nil
+	}
+
+	for _, label := range dns.SplitDomainName(name) {
+		for _, trigger := range rpzTriggers {
+			if label == trigger {
+				return nil
+			}
+		}
+	}
+
+	host := &Host{
+		Domain: name,
+		Type:   DIRECT,
+	}
+
+	if strings.HasPrefix(name, "*.") {
+		host.Type = WILDCARD
+	}
+
+	switch r := rr.(type) {
+	case *dns.CNAME:
+		switch r.Target {
+		case ".":
+			host.Action = NXDOMAIN
+		case "*.":
+			host.Action = NODATA
+		default:
+			// rpz-passthru, rpz-drop, and cname rewrites
+			return nil
+		}
+	case *dns.A:
+		host.Action = REDIRECT
+		host.IP = r.A
+	case *dns.AAAA:
+		host.Action = REDIRECT
+		host.IP = r.AAAA
+	default:
+		return nil
+	}
+
+	return host
+}
+
+// merge adds the local-data address to the host, where the IPv4 address
+// is kept in IP and the IPv6 address in IPv6 when the host has both, and
+// the first address of each type is kept.
+func (h *Host) merge(ip net.IP) {
+	if ip.To4() != nil {
+		if h.IP.To4() == nil {
+			h.IP, h.IPv6 = ip, h.IP
+		}
+
+		return
+	}
+
+	if h.IP.To4() != nil && h.IPv6 == nil {
+		h.IPv6 = ip
+	}
+}
@@ -0,0 +1,171 @@
+package void
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func Test_Parse_RPZ(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	f, err := os.Open("testdata/rpz/threats.rpz")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hosts := Parse(ctx, &NOOPLogger{}, RPZ, f)
+
+	expected := Hosts{
+		{Domain: "malware.example.com", Type: DIRECT, Action: NXDOMAIN},
+		{Domain: "*.malware.example.com", Type: WILDCARD, Action: NXDOMAIN},
+		{Domain: "tracker.example.com", Type: DIRECT, Action: NODATA},
+		{
+			Domain: "ads.example.com",
+			Type:   DIRECT,
+			Action: REDIRECT,
+			IP:     net.ParseIP("0.0.0.0"),
+			IPv6:   net.ParseIP("::"),
+		},
+		{
+			Domain: "walled.example.com",
+			Type:   DIRECT,
+			Action: REDIRECT,
+			IP:     net.ParseIP("192.168.0.10"),
+		},
+	}
+
+	if len(hosts) != len(expected) {
+		t.Fatalf("expected %d hosts, got %d", len(expected), len(hosts))
+	}
+
+	for i, host := range hosts {
+		e := expected[i]
+		if host.Domain != e.Domain ||
+			host.Type != e.Type ||
+			host.Action != e.Action ||
+			!host.IP.Equal(e.IP) ||
+			!host.IPv6.Equal(e.IPv6) {
+			t.Fatalf("expected host %d to be %+v, got %+v", i, e, host)
+		}
+	}
+}
+
+func Test_Parse_RPZ_NoOrigin(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	body := io.NopCloser(strings.NewReader(
+		"bad.example.com 300 CNAME .\n*.bad.example.com 300 CNAME *.\n",
+	))
+
+	hosts := Parse(ctx, &NOOPLogger{}, RPZ, body)
+	if len(hosts) != 2 {
+		t.Fatalf("expected 2 hosts, got %d", len(hosts))
+	}
+
+	if hosts[0].Domain != "bad.example.com" || hosts[0].Action != NXDOMAIN {
+		t.Fatalf("unexpected host %+v", hosts[0])
+	}
+
+	if hosts[1].Type != WILDCARD || hosts[1].Action != NODATA {
+		t.Fatalf("unexpected host %+v", hosts[1])
+	}
+}
+
+func Test_Block_RPZ_LocalData(t *testing.T) {
+	pctx, pcancel := context.WithCancel(context.Background())
+	defer pcancel()
+
+	f, err := os.Open("testdata/rpz/threats.rpz")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := Parse(pctx, &NOOPLogger{}, RPZ, f).Records("threats.rpz", "")
+
+	block, err := BlockResolver(pctx, &NOOPLogger{}, records...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		name     string
+		qtype    uint16
+		expected net.IP
+	}{
+		"ads-a": {
+			name:     "ads.example.com.",
+			qtype:    dns.TypeA,
+			expected: net.ParseIP("0.0.0.0"),
+		},
+		"ads-aaaa": {
+			name:     "ads.example.com.",
+			qtype:    dns.TypeAAAA,
+			expected: net.ParseIP("::"),
+		},
+		"walled-a": {
+			name:     "walled.example.com.",
+			qtype:    dns.TypeA,
+			expected: net.ParseIP("192.168.0.10"),
+		},
+		"walled-aaaa": {
+			name:  "walled.example.com.",
+			qtype: dns.TypeAAAA,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(pctx)
+			defer cancel()
+
+			w := &TestWriter{}
+			req := &Request{
+				ctx:    ctx,
+				cancel: cancel,
+				w:      w,
+				r:      Question(t, test.name, test.qtype),
+			}
+
+			_, pass := block.Intercept(ctx, req)
+			if pass {
+				t.Fatal("expected request to be blocked")
+			}
+
+			if w.response == nil {
+				t.Fatal("expected response")
+			}
+
+			if test.expected == nil {
+				if len(w.response.Answer) != 0 {
+					t.Fatalf("expected no answers, got %v", w.response.Answer)
+				}
+
+				return
+			}
+
+			if len(w.response.Answer) != 1 {
+				t.Fatalf("expected 1 answer, got %v", w.response.Answer)
+			}
+
+			var ip net.IP
+			switch rr := w.response.Answer[0].(type) {
+			case *dns.A:
+				ip = rr.A
+			case *dns.AAAA:
+				ip = rr.AAAA
+			}
+
+			if !ip.Equal(test.expected) {
+				t.Fatalf("expected %s, got %s", test.expected, ip)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package void
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// safeSearchTimeout bounds the resolution of the safe search endpoint.
+const safeSearchTimeout = time.Second * 5
+
+// DefaultSafeSearch maps the domains of the search engines and YouTube to
+// their safe search and restricted mode endpoints.
+//
+//nolint:gochecknoglobals // default configuration
+var DefaultSafeSearch = map[string]string{
+	"google.com":               "forcesafesearch.google.com",
+	"www.google.com":           "forcesafesearch.google.com",
+	"bing.com":                 "strict.bing.com",
+	"www.bing.com":             "strict.bing.com",
+	"duckduckgo.com":           "safe.duckduckgo.com",
+	"www.duckduckgo.com":       "safe.duckduckgo.com",
+	"start.duckduckgo.com":     "safe.duckduckgo.com",
+	"youtube.com":              "restrict.youtube.com",
+	"www.youtube.com":          "restrict.youtube.com",
+	"m.youtube.com":            "restrict.youtube.com",
+	"youtubei.googleapis.com":  "restrict.youtube.com",
+	"youtube.googleapis.com":   "restrict.youtube.com",
+	"www.youtube-nocookie.com": "restrict.youtube.com",
+}
+
+// SafeSearchConfig configures the rewriting of the search engines and
+// YouTube to their safe search and restricted mode endpoints.
+type SafeSearchConfig struct {
+	// Enabled enables safe search for every client, which the client
+	// groups may override, see Group.SafeSearch.
+	Enabled bool
+
+	// Mapping replaces the endpoints of the domains in DefaultSafeSearch,
+	// where an empty endpoint removes the domain.
+	Mapping map[string]string
+}
+
+// mapping merges the configured mapping into the default mapping.
+func (c SafeSearchConfig) mapping() (map[string]string, error) {
+	merged := make(map[string]string, len(DefaultSafeSearch)+len(c.Mapping))
+	for _, m := range []map[string]string{DefaultSafeSearch, c.Mapping} {
+		for domain, target := range m {
+			domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+			target = strings.ToLower(strings.TrimSuffix(target, "."))
+
+			if target == "" {
+				delete(merged, domain)
+				continue
+			}
+
+			if _, ok := dns.IsDomainName(target); !ok {
+				return nil, fmt.Errorf(
+					"invalid safe search endpoint [%s] for [%s]",
+					target,
+					domain,
+				)
+			}
+
+			merged[domain] = target
+		}
+	}
+
+	return merged, nil
+}
+
+// SafeSearchResolver creates the safe search stage of the pipeline which
+// answers the requests for the domains of the mapping with a CNAME to
+// their endpoint, along with the records of the endpoint resolved by the
+// upstream.
+func SafeSearchResolver(
+	ctx context.Context,
+	logger Logger,
+	upstream chan<- *Request,
+	mapping map[string]string,
+) (*SafeSearch, error) {
+	err := checkNil(ctx, logger, upstream)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SafeSearch{
+		ctx:      ctx,
+		logger:   logger,
+		upstream: upstream,
+		mapping:  mapping,
+	}, nil
+}
+
+// SafeSearch rewrites the requests for search engines to their safe
+// search endpoints.
+type SafeSearch struct {
+	ctx      context.Context
+	logger   Logger
+	upstream chan<- *Request
+	mapping  map[string]string
+}
+
+func (s *SafeSearch) Intercept(
+	ctx context.Context,
+	req *Request,
+) (*Request, bool) {
+	target, ok := s.mapping[strings.ToLower(req.Record())]
+	if !ok {
+		return req, true
+	}
+
+	s.logger.Debugw(
+		"matched",
+		"category", SAFESEARCH,
+		"name", req.r.Question[0].Name,
+		"type", dns.Type(req.r.Question[0].Qtype),
+		"target", target,
+		"client", req.client,
+	)
+
+	res, err := s.resolve(ctx, req, dns.Fqdn(target))
+	if err != nil {
+		s.logger.Errorw(
+			"failed to resolve safe search endpoint",
+			"category", SAFESEARCH,
+			"name", req.r.Question[0].Name,
+			"target", target,
+			"error", err,
+		)
+
+		// Fail closed so that safe search is not bypassed
+		err = req.Fail(dns.RcodeServerFailure)
+	} else {
+		err = req.Answer(res)
+	}
+
+	if err != nil {
+		s.logger.Errorw(
+			"failed to answer",
+			"category", SAFESEARCH,
+			"request", req.String(),
+			"error", err,
+		)
+	}
+
+	return nil, false
+}
+
+// resolve resolves the endpoint with the upstream of the request, and
+// returns the response to the request with the CNAME to the endpoint.
+func (s *SafeSearch) resolve(
+	ctx context.Context,
+	req *Request,
+	target string,
+) (*dns.Msg, error) {
+	ctx, cancel := context.WithTimeout(ctx, safeSearchTimeout)
+	defer cancel()
+
+	q := req.r.Question[0]
+
+	w := make(resolveWriter, 1)
+	sub := NewRequest(
+		ctx,
+		w,
+		(&dns.Msg{}).SetQuestion(target, q.Qtype),
+		req.server,
+		req.client,
+	)
+	sub.group = req.group
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case s.upstream <- sub:
+	}
+
+	var resolved *dns.Msg
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case resolved = <-w:
+	}
+
+	ttl := uint32(DEFAULTTTL)
+	if len(resolved.Answer) > 0 {
+		ttl = resolved.Answer[0].Header().Ttl
+	}
+
+	res := (&dns.Msg{}).SetReply(req.r)
+	res.Rcode = resolved.Rcode
+	res.Answer = append([]dns.RR{&dns.CNAME{
+		Hdr: dns.RR_Header{
+			Name:   q.Name,
+			Rrtype: dns.TypeCNAME,
+			Class:  dns.ClassINET,
+			Ttl:    ttl,
+		},
+		Target: target,
+	}}, resolved.Answer...)
+
+	return res, nil
+}
+
+// resolveWriter is a Writer which captures the response of a request
+// resolved on behalf of another request.
+type resolveWriter chan *dns.Msg
+
+func (w resolveWriter) WriteMsg(res *dns.Msg) error {
+	select {
+	case w <- res:
+	default:
+	}
+
+	return nil
+}
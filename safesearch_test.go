@@ -0,0 +1,171 @@
+package void
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// resolver answers the requests sent to the upstream with an A record.
+func resolver(ctx context.Context, t *testing.T, ip string) chan *Request {
+	upstream := make(chan *Request)
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case req := <-upstream:
+				res := (&dns.Msg{}).SetReply(req.r)
+				res.Answer = []dns.RR{&dns.A{
+					Hdr: dns.RR_Header{
+						Name:   req.r.Question[0].Name,
+						Rrtype: dns.TypeA,
+						Class:  dns.ClassINET,
+						Ttl:    300,
+					},
+					A: net.ParseIP(ip),
+				}}
+
+				err := req.Answer(res)
+				if err != nil {
+					t.Error(err)
+				}
+			}
+		}
+	}()
+
+	return upstream
+}
+
+func Test_SafeSearch_Intercept(t *testing.T) {
+	pctx, pcancel := context.WithCancel(context.Background())
+	defer pcancel()
+
+	mapping, err := SafeSearchConfig{
+		Mapping: map[string]string{
+			"www.bing.com":        "",
+			"search.example.tld.": "safe.example.tld.",
+		},
+	}.mapping()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ss, err := SafeSearchResolver(
+		pctx,
+		&NOOPLogger{},
+		resolver(pctx, t, "216.239.38.120"),
+		mapping,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		name   string
+		target string
+	}{
+		"google":       {name: "www.google.com.", target: "forcesafesearch.google.com."},
+		"youtube":      {name: "WWW.YouTube.com.", target: "restrict.youtube.com."},
+		"duckduckgo":   {name: "duckduckgo.com.", target: "safe.duckduckgo.com."},
+		"configured":   {name: "search.example.tld.", target: "safe.example.tld."},
+		"removed":      {name: "www.bing.com."},
+		"unmapped":     {name: "example.tld."},
+		"not-suffixed": {name: "mail.google.com."},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(pctx)
+			defer cancel()
+
+			w := &TestWriter{}
+			req := &Request{
+				ctx:    ctx,
+				cancel: cancel,
+				w:      w,
+				r:      Question(t, test.name, dns.TypeA),
+			}
+
+			_, pass := ss.Intercept(pctx, req)
+			if pass != (test.target == "") {
+				t.Fatalf("expected pass %v, got %v", test.target == "", pass)
+			}
+
+			if test.target == "" {
+				return
+			}
+
+			if w.response == nil || len(w.response.Answer) != 2 {
+				t.Fatalf("expected cname and address, got %v", w.response)
+			}
+
+			cname, ok := w.response.Answer[0].(*dns.CNAME)
+			if !ok {
+				t.Fatalf("expected cname, got %s", w.response.Answer[0])
+			}
+
+			if cname.Hdr.Name != test.name || cname.Target != test.target {
+				t.Fatalf("expected %s cname to %s, got %s", test.name, test.target, cname)
+			}
+
+			// The cname shares the ttl of the resolved address for caching
+			if cname.Hdr.Ttl != 300 {
+				t.Fatalf("expected ttl 300, got %d", cname.Hdr.Ttl)
+			}
+
+			a, ok := w.response.Answer[1].(*dns.A)
+			if !ok || a.Hdr.Name != test.target {
+				t.Fatalf("expected address of %s, got %s", test.target, w.response.Answer[1])
+			}
+		})
+	}
+}
+
+func Test_SafeSearch_Unresolved(t *testing.T) {
+	pctx, pcancel := context.WithCancel(context.Background())
+	defer pcancel()
+
+	mapping, err := SafeSearchConfig{}.mapping()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing reads from the upstream
+	ss, err := SafeSearchResolver(pctx, &NOOPLogger{}, make(chan *Request), mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(pctx, time.Millisecond*10)
+	defer cancel()
+
+	w := &TestWriter{}
+	req := &Request{
+		ctx:    pctx,
+		cancel: func() {},
+		w:      w,
+		r:      Question(t, "www.google.com.", dns.TypeA),
+	}
+
+	if _, pass := ss.Intercept(ctx, req); pass {
+		t.Fatal("expected request to be answered")
+	}
+
+	if w.response == nil || w.response.Rcode != dns.RcodeServerFailure {
+		t.Fatalf("expected servfail, got %v", w.response)
+	}
+}
+
+func Test_SafeSearchConfig_Mapping(t *testing.T) {
+	_, err := SafeSearchConfig{
+		Mapping: map[string]string{"www.google.com": "safe..google.com"},
+	}.mapping()
+	if err == nil {
+		t.Fatal("expected error for invalid endpoint")
+	}
+}
@@ -0,0 +1,189 @@
+package void
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const clockFormat = "15:04"
+
+// Schedule is a daily window during which the records of a source or
+// category are applied. Windows where the start is after the end span
+// midnight and belong to the day they start, so "21:00" to "07:00" on
+// friday is active until 07:00 on saturday.
+//
+// Days are the names of the week days (e.g. mon or monday), or weekdays
+// and weekends, where no days is every day. Start and end are in the
+// 24 hour "15:04" format, where equal (or empty) times are the whole
+// day. The timezone is an IANA name, defaulting to the local timezone.
+type Schedule struct {
+	Days     []string
+	Start    string
+	End      string
+	Timezone string
+
+	once  sync.Once
+	err   error
+	days  [7]bool
+	start time.Duration
+	end   time.Duration
+	loc   *time.Location
+}
+
+// Validate parses the schedule, returning an error for invalid days,
+// times, or timezones.
+func (s *Schedule) Validate() error {
+	s.once.Do(func() {
+		s.err = s.parse()
+	})
+
+	return s.err
+}
+
+// Active indicates if the schedule is active at the time. Invalid
+// schedules are always active so that their records are not ignored.
+func (s *Schedule) Active(t time.Time) bool {
+	if s.Validate() != nil {
+		return true
+	}
+
+	t = t.In(s.loc)
+	day := t.Weekday()
+	now := time.Duration(t.Hour())*time.Hour +
+		time.Duration(t.Minute())*time.Minute
+
+	switch {
+	case s.start == s.end:
+		return s.days[day]
+	case s.start < s.end:
+		return s.days[day] && now >= s.start && now < s.end
+	case now >= s.start:
+		return s.days[day]
+	case now < s.end:
+		// The window started on the previous day
+		return s.days[(day+6)%7]
+	default:
+		return false
+	}
+}
+
+func (s *Schedule) parse() error {
+	var err error
+
+	s.loc = time.Local
+	if s.Timezone != "" {
+		s.loc, err = time.LoadLocation(s.Timezone)
+		if err != nil {
+			return fmt.Errorf("invalid schedule timezone [%s]: %w", s.Timezone, err)
+		}
+	}
+
+	s.start, err = clock(s.Start)
+	if err != nil {
+		return err
+	}
+
+	s.end, err = clock(s.End)
+	if err != nil {
+		return err
+	}
+
+	if len(s.Days) == 0 {
+		for i := range s.days {
+			s.days[i] = true
+		}
+
+		return nil
+	}
+
+	for _, d := range s.Days {
+		days, ok := weekdays[strings.ToLower(strings.TrimSpace(d))]
+		if !ok {
+			return fmt.Errorf("invalid schedule day [%s]", d)
+		}
+
+		for _, day := range days {
+			s.days[day] = true
+		}
+	}
+
+	return nil
+}
+
+// clock parses a time of day into the duration since midnight.
+func clock(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	t, err := time.Parse(clockFormat, value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid schedule time [%s]: %w", value, err)
+	}
+
+	return time.Duration(t.Hour())*time.Hour +
+		time.Duration(t.Minute())*time.Minute, nil
+}
+
+// weekdays are the names of the schedule days.
+//
+//nolint:gochecknoglobals // constant lookup
+var weekdays = map[string][]time.Weekday{
+	"sun":       {time.Sunday},
+	"sunday":    {time.Sunday},
+	"mon":       {time.Monday},
+	"monday":    {time.Monday},
+	"tue":       {time.Tuesday},
+	"tuesday":   {time.Tuesday},
+	"wed":       {time.Wednesday},
+	"wednesday": {time.Wednesday},
+	"thu":       {time.Thursday},
+	"thursday":  {time.Thursday},
+	"fri":       {time.Friday},
+	"friday":    {time.Friday},
+	"sat":       {time.Saturday},
+	"saturday":  {time.Saturday},
+	"weekdays": {
+		time.Monday,
+		time.Tuesday,
+		time.Wednesday,
+		time.Thursday,
+		time.Friday,
+	},
+	"weekends": {time.Saturday, time.Sunday},
+}
+
+// Schedules are the schedules of the record categories.
+type Schedules map[string]*Schedule
+
+// Validate validates each of the schedules.
+func (s Schedules) Validate() error {
+	for category, schedule := range s {
+		if schedule == nil {
+			continue
+		}
+
+		err := schedule.Validate()
+		if err != nil {
+			return fmt.Errorf("category [%s]: %w", category, err)
+		}
+	}
+
+	return nil
+}
+
+// schedule returns the schedule of the record, which is the schedule of
+// its source, or otherwise the schedule of its category.
+func (s Schedules) schedule(r *Record) *Schedule {
+	if r.Schedule != nil {
+		return r.Schedule
+	}
+
+	if r.Category == "" {
+		return nil
+	}
+
+	return s[strings.ToLower(r.Category)]
+}
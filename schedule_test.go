@@ -0,0 +1,299 @@
+package void
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func Test_Schedule_Active(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	// 2024-01-05 is a friday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, time.January, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := map[string]struct {
+		schedule *Schedule
+		time     time.Time
+		active   bool
+	}{
+		"every-day": {
+			schedule: &Schedule{Timezone: "UTC"},
+			time:     at(5, 12, 0),
+			active:   true,
+		},
+		"day-inside": {
+			schedule: &Schedule{Start: "09:00", End: "17:00", Timezone: "UTC"},
+			time:     at(5, 9, 0),
+			active:   true,
+		},
+		"day-end": {
+			schedule: &Schedule{Start: "09:00", End: "17:00", Timezone: "UTC"},
+			time:     at(5, 17, 0),
+		},
+		"overnight-evening": {
+			schedule: &Schedule{
+				Days:     []string{"weekdays"},
+				Start:    "21:00",
+				End:      "07:00",
+				Timezone: "UTC",
+			},
+			time:   at(5, 22, 30),
+			active: true,
+		},
+		"overnight-morning-after-weekday": {
+			schedule: &Schedule{
+				Days:     []string{"weekdays"},
+				Start:    "21:00",
+				End:      "07:00",
+				Timezone: "UTC",
+			},
+			// saturday morning belongs to the friday window
+			time:   at(6, 6, 59),
+			active: true,
+		},
+		"overnight-morning-after-weekend": {
+			schedule: &Schedule{
+				Days:     []string{"weekdays"},
+				Start:    "21:00",
+				End:      "07:00",
+				Timezone: "UTC",
+			},
+			// sunday morning belongs to the saturday window
+			time: at(7, 6, 0),
+		},
+		"overnight-outside": {
+			schedule: &Schedule{
+				Days:     []string{"weekdays"},
+				Start:    "21:00",
+				End:      "07:00",
+				Timezone: "UTC",
+			},
+			time: at(5, 12, 0),
+		},
+		"weekend-day": {
+			schedule: &Schedule{Days: []string{"Sat", "sunday"}, Timezone: "UTC"},
+			time:     at(6, 12, 0),
+			active:   true,
+		},
+		"timezone": {
+			schedule: &Schedule{Start: "21:00", End: "23:00", Timezone: ny.String()},
+			// 02:00 UTC is 21:00 in new york
+			time:   at(6, 2, 0),
+			active: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := test.schedule.Validate()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if active := test.schedule.Active(test.time); active != test.active {
+				t.Fatalf("expected active %v, got %v", test.active, active)
+			}
+		})
+	}
+}
+
+func Test_Schedule_Validate(t *testing.T) {
+	tests := map[string]*Schedule{
+		"day":      {Days: []string{"someday"}},
+		"start":    {Start: "9am"},
+		"end":      {End: "25:00"},
+		"timezone": {Timezone: "Nowhere/Void"},
+	}
+
+	for name, schedule := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := schedule.Validate(); err == nil {
+				t.Fatal("expected error")
+			}
+
+			// Invalid schedules do not disable their records
+			if !schedule.Active(time.Now()) {
+				t.Fatal("expected invalid schedule to be active")
+			}
+		})
+	}
+}
+
+func Test_Block_Intercept_Schedule(t *testing.T) {
+	pctx, pcancel := context.WithCancel(context.Background())
+	defer pcancel()
+
+	block, err := BlockResolver(pctx, &NOOPLogger{},
+		&Record{Pattern: "social.example.tld", Type: DIRECT, Category: "Social"},
+		&Record{
+			Pattern:  "source.example.tld",
+			Type:     DIRECT,
+			Category: "social",
+			Schedule: &Schedule{Start: "12:00", End: "13:00", Timezone: "UTC"},
+		},
+		&Record{Pattern: "ads.example.tld", Type: DIRECT, Category: "ads"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block.schedules = categories(Schedules{
+		"SOCIAL": {Start: "21:00", End: "07:00", Timezone: "UTC"},
+	})
+
+	tests := map[string]struct {
+		name    string
+		time    time.Time
+		blocked bool
+	}{
+		"category-active": {
+			name:    "social.example.tld.",
+			time:    time.Date(2024, time.January, 5, 23, 0, 0, 0, time.UTC),
+			blocked: true,
+		},
+		"category-inactive": {
+			name: "social.example.tld.",
+			time: time.Date(2024, time.January, 5, 12, 30, 0, 0, time.UTC),
+		},
+		"source-active": {
+			name:    "source.example.tld.",
+			time:    time.Date(2024, time.January, 5, 12, 30, 0, 0, time.UTC),
+			blocked: true,
+		},
+		"source-inactive": {
+			name: "source.example.tld.",
+			time: time.Date(2024, time.January, 5, 23, 0, 0, 0, time.UTC),
+		},
+		"unscheduled": {
+			name:    "ads.example.tld.",
+			time:    time.Date(2024, time.January, 5, 12, 30, 0, 0, time.UTC),
+			blocked: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(pctx)
+			defer cancel()
+
+			block.now = func() time.Time { return test.time }
+
+			w := &TestWriter{}
+			req := &Request{
+				ctx:    ctx,
+				cancel: cancel,
+				w:      w,
+				r:      Question(t, test.name, dns.TypeA),
+			}
+
+			_, pass := block.Intercept(ctx, req)
+			if pass == test.blocked {
+				t.Fatalf("expected blocked %v, got pass %v", test.blocked, pass)
+			}
+
+			if test.blocked && w.response == nil {
+				t.Fatal("expected response")
+			}
+		})
+	}
+}
+
+func Test_Block_Schedule_Cache(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cache, err := CacheResolver(ctx, &NOOPLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := BlockResolver(ctx, &NOOPLogger{}, &Record{
+		Pattern:  "games.example.tld",
+		Type:     DIRECT,
+		Schedule: &Schedule{Start: "08:00", End: "15:00", Timezone: "UTC"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// resolve runs the request through the cache and block stages, where
+	// requests which pass both are answered as the upstream
+	resolve := func(now time.Time) *dns.Msg {
+		t.Helper()
+
+		block.now = func() time.Time { return now }
+
+		rctx, rcancel := context.WithCancel(ctx)
+		defer rcancel()
+
+		w := &TestWriter{}
+		req := &Request{
+			ctx:    rctx,
+			cancel: rcancel,
+			w:      w,
+			r:      Question(t, "games.example.tld.", dns.TypeA),
+		}
+
+		for _, stage := range []Interceptor{cache, block} {
+			var pass bool
+			req, pass = stage.Intercept(ctx, req)
+			if !pass {
+				return w.response
+			}
+		}
+
+		res := (&dns.Msg{}).SetReply(req.r)
+		res.Answer = []dns.RR{&dns.A{
+			Hdr: dns.RR_Header{
+				Name:   "games.example.tld.",
+				Rrtype: dns.TypeA,
+				Class:  dns.ClassINET,
+				Ttl:    DEFAULTTTL,
+			},
+			A: net.ParseIP("93.184.216.34"),
+		}}
+
+		err := req.Answer(res)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return w.response
+	}
+
+	day := func(hour int) time.Time {
+		return time.Date(2024, time.January, 5, hour, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		time  time.Time
+		rcode int
+	}{
+		{time: day(7), rcode: dns.RcodeSuccess},
+		{time: day(8), rcode: dns.RcodeNameError},
+		{time: day(14), rcode: dns.RcodeNameError},
+		{time: day(15), rcode: dns.RcodeSuccess},
+	}
+
+	// The steps are sequential as each crosses a boundary of the schedule
+	for _, test := range tests {
+		res := resolve(test.time)
+		if res.Rcode != test.rcode {
+			t.Fatalf(
+				"%s: expected rcode %s, got %s",
+				test.time.Format(time.Kitchen),
+				dns.RcodeToString[test.rcode],
+				dns.RcodeToString[res.Rcode],
+			)
+		}
+	}
+}
@@ -0,0 +1,612 @@
+// Package void is a DNS sink hole and local resolver which evaluates
+// requests in a pipeline of stages (cache, local records, allow and block
+// lists) before forwarding them to the upstream DNS servers.
+//
+// The Server wires the stages and listeners together from a Config, and
+// custom stages implementing the Interceptor interface are registered
+// into the pipeline by name.
+package void
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/miekg/dns"
+	"go.atomizer.io/stream"
+)
+
+// Config configures the listeners and resolver stages of a Server.
+type Config struct {
+	// Listen are the addresses the server listens on.
+	Listen []ListenAddr
+
+	// Sockets are pre-opened sockets (see Activated) which are served
+	// in place of the Listen addresses.
+	Sockets []Socket
+
+	// TCP configures the connections of stream based listeners.
+	TCP *TCPConfig
+
+	// TLS is the server tls configuration shared by the tcp-tls, https,
+	// and quic listeners.
+	TLS *tls.Config
+
+	// Upstreams are the addresses of the upstream DNS servers in the
+	// format <proto>://<ip>[:<port>].
+	Upstreams []string
+
+	// Zones forward the requests for their domains to the upstreams of
+	// the zone, taking precedence over the global and group upstreams.
+	Zones []Zone
+
+	// Pipeline is the order of the resolver stages, which must end with
+	// the upstream stage, defaults to DefaultPipeline. The access control,
+	// validation, and group selection stages always run first.
+	Pipeline []Category
+
+	// Local, Allow, and Block are the records of the local resolver and
+	// the allow and block lists.
+	Local []*Record
+	Allow []*Record
+	Block []*Record
+
+	// PTRTTL is the TTL of the PTR records synthesized from the IPs of
+	// the local records, defaults to DEFAULTTTL.
+	PTRTTL uint32
+
+	// BlockMode is the default response to blocked requests, where
+	// BlockModes are the responses for the categories of the blocked
+	// records. The modes of sources take precedence over both.
+	BlockMode  BlockMode
+	BlockModes BlockModes
+
+	// Schedules limit when the blocked records of the categories are
+	// applied, where the schedule of a source takes precedence.
+	Schedules Schedules
+
+	// SafeSearch rewrites the search engines to their safe search
+	// endpoints, for every client or the client groups which enable it.
+	SafeSearch SafeSearchConfig
+
+	// Groups are the client groups which replace the lists and
+	// upstreams for their clients.
+	Groups []Group
+
+	ACL       ACLConfig
+	RateLimit RateLimitConfig
+
+	// Rebind enables the DNS rebinding protection of upstream responses
+	// when set, where the names of the local records of the server and
+	// the client groups are allowed to resolve to private addresses.
+	Rebind *RebindConfig
+
+	// Control is the address of the http control interface for pausing
+	// blocking, see ControlHandler. The control interface is disabled
+	// when empty.
+	Control string
+
+	// ControlToken is the bearer token required by the requests of the
+	// control interface, which must be set when it is enabled.
+	ControlToken string
+
+	// Metrics enables logging of the trace of each request, with the
+	// time spent in every stage of the pipeline, once it is answered.
+	Metrics bool
+}
+
+// NewServer creates the stages of the pipeline from the configuration.
+// The pipeline is built when the server is first served so that custom
+// stages can be registered after the server is created.
+func NewServer(ctx context.Context, logger Logger, cfg Config) (*Server, error) {
+	err := checkNil(ctx, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Control != "" && cfg.ControlToken == "" {
+		return nil, fmt.Errorf("control [%s] missing token", cfg.Control)
+	}
+
+	handler, requests := Convert(ctx, logger, cfg.Metrics)
+
+	if cfg.RateLimit.Enabled() {
+		limiter, err := RateLimiter(ctx, logger, cfg.RateLimit)
+		if err != nil {
+			return nil, err
+		}
+
+		limiter.metrics = cfg.Metrics
+		handler = limiter.Limit(handler)
+	}
+
+	pipeline, err := NewPipeline(logger)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		ctx:      ctx,
+		logger:   logger,
+		cfg:      cfg,
+		handler:  handler,
+		requests: requests,
+		pipeline: pipeline,
+		order:    []Category{VALIDATE},
+	}
+
+	groups := make([]string, 0, len(cfg.Groups))
+	for _, g := range cfg.Groups {
+		groups = append(groups, g.Name)
+	}
+
+	s.pauses, err = NewPauses(ctx, logger, groups...)
+	if err != nil {
+		return nil, err
+	}
+
+	zones := make([]*Record, 0, len(cfg.Zones)*2)
+	for _, z := range cfg.Zones {
+		r, err := z.records()
+		if err != nil {
+			return nil, err
+		}
+
+		zones = append(zones, r...)
+	}
+
+	// The forwarded zones are generally private networks which are
+	// allowed to resolve to private addresses
+	if cfg.Rebind != nil {
+		local := append([]*Record{}, cfg.Local...)
+		for _, g := range cfg.Groups {
+			local = append(local, g.Local...)
+		}
+
+		s.rebind, err = RebindGuard(
+			ctx,
+			logger,
+			*cfg.Rebind,
+			append(local, zones...)...,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = s.zones()
+	if err != nil {
+		return nil, err
+	}
+
+	s.upstream, err = s.upstreams(cfg.Upstreams...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.stages()
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Server is a DNS server which answers requests using the pipeline.
+type Server struct {
+	ctx      context.Context
+	logger   Logger
+	cfg      Config
+	handler  HandleFunc
+	requests <-chan *Request
+	pipeline *Pipeline
+	upstream chan *Request
+	groups   *Groups
+	pauses   *Pauses
+	rebind   *Rebind
+	forward  *Forward
+
+	// order is the fixed start of the pipeline before the
+	// configured stages
+	order []Category
+
+	once     sync.Once
+	buildErr error
+}
+
+// fanOut fans out the requests sent to the returned channel to every
+// upstream, where the first response is written to the client.
+func (s *Server) fanOut(addresses ...string) (chan *Request, error) {
+	upstream, err := Up(s.ctx, s.logger, addresses...)
+	if err != nil {
+		return nil, err
+	}
+
+	i := &Initializer[*Request, *Request]{s.logger}
+
+	up := make([]chan<- *Request, 0, len(upstream))
+	for _, u := range upstream {
+		toUp := make(chan *Request)
+		i.Scale(s.ctx, toUp, u.Intercept)
+		up = append(up, toUp)
+	}
+
+	// The upstream span stays open until one of the upstreams
+	// writes the response
+	enter := enterUpstream
+	if s.rebind != nil {
+		enter = func(ctx context.Context, req *Request) (*Request, bool) {
+			req, _ = s.rebind.Intercept(ctx, req)
+			return enterUpstream(ctx, req)
+		}
+	}
+
+	in := make(chan *Request)
+	go stream.FanOut(
+		s.ctx,
+		i.Scale(s.ctx, in, enter),
+		up...,
+	)
+
+	return in, nil
+}
+
+// zones creates the conditional forwarding of the zones, where each zone
+// fans out to its own upstreams.
+func (s *Server) zones() error {
+	if len(s.cfg.Zones) == 0 {
+		return nil
+	}
+
+	zones := make(map[string]chan<- *Request, len(s.cfg.Zones))
+	for _, z := range s.cfg.Zones {
+		domain, err := z.domain()
+		if err != nil {
+			return err
+		}
+
+		if _, ok := zones[domain]; ok {
+			return fmt.Errorf("duplicate zone [%s]", domain)
+		}
+
+		up, err := s.fanOut(z.Upstreams...)
+		if err != nil {
+			return fmt.Errorf("zone [%s]: %w", domain, err)
+		}
+
+		zones[domain] = up
+	}
+
+	var err error
+	s.forward, err = Forwarder(s.ctx, s.logger, zones)
+
+	return err
+}
+
+// upstreams creates the fan out to the upstreams, where the requests for
+// the forwarded zones are instead sent to the upstreams of their zone.
+func (s *Server) upstreams(addresses ...string) (chan *Request, error) {
+	up, err := s.fanOut(addresses...)
+	if err != nil || s.forward == nil {
+		return up, err
+	}
+
+	i := &Initializer[*Request, *Request]{s.logger}
+
+	in := make(chan *Request)
+	go stream.Pipe(s.ctx, i.Scale(s.ctx, in, s.forward.Intercept), up)
+
+	return in, nil
+}
+
+// lists creates the local, safe search, allow, and block stages for the
+// lists of the group, where allowed requests are sent directly to the
+// upstream.
+func (s *Server) lists(
+	g Group,
+	upstream chan<- *Request,
+) (map[Category]Interceptor, error) {
+	l, err := LocalResolver(s.ctx, s.logger, g.Local...)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.cfg.PTRTTL > 0 {
+		l.ptrTTL = s.cfg.PTRTTL
+	}
+
+	a, err := AllowResolver(s.ctx, s.logger, upstream, g.Allow...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Safe search passes every request when it is disabled for the
+	// group so that the stage is always registered
+	var mapping map[string]string
+	enabled := s.cfg.SafeSearch.Enabled
+	if g.SafeSearch != nil {
+		enabled = *g.SafeSearch
+	}
+
+	if enabled {
+		mapping, err = s.cfg.SafeSearch.mapping()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ss, err := SafeSearchResolver(s.ctx, s.logger, upstream, mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := BlockResolver(s.ctx, s.logger, g.Block...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = g.Schedules.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	b.mode = s.cfg.BlockMode
+	if g.Mode != nil {
+		err = g.Mode.Validate()
+		if err != nil {
+			return nil, fmt.Errorf("client group [%s]: %w", g.Name, err)
+		}
+
+		b.mode = *g.Mode
+	}
+
+	b.modes = categories(s.cfg.BlockModes)
+	b.schedules = categories(s.cfg.Schedules, g.Schedules)
+	b.pauses = s.pauses
+
+	return map[Category]Interceptor{
+		LOCAL:      l,
+		SAFESEARCH: ss,
+		ALLOW:      a,
+		BLOCK:      b,
+	}, nil
+}
+
+// stages registers the built in stages of the pipeline.
+func (s *Server) stages() error {
+	err := s.cfg.BlockMode.Validate()
+	if err != nil {
+		return err
+	}
+
+	err = s.cfg.BlockModes.Validate()
+	if err != nil {
+		return err
+	}
+
+	validator, err := NewValidator(s.ctx, s.logger)
+	if err != nil {
+		return err
+	}
+
+	cache, err := CacheResolver(s.ctx, s.logger)
+	if err != nil {
+		return err
+	}
+
+	lists, err := s.lists(Group{
+		Local:     s.cfg.Local,
+		Allow:     s.cfg.Allow,
+		Block:     s.cfg.Block,
+		Schedules: s.cfg.Schedules,
+	}, s.upstream)
+	if err != nil {
+		return err
+	}
+
+	stages := map[Category]Interceptor{
+		VALIDATE: validator,
+		CACHE:    cache,
+	}
+
+	for name, stage := range lists {
+		stages[name] = stage
+	}
+
+	// Client groups are selected after validation, replacing the lists
+	// for the requests of the group clients
+	if len(s.cfg.Groups) > 0 {
+		s.groups, err = s.clientGroups()
+		if err != nil {
+			return err
+		}
+
+		for name, stage := range lists {
+			stages[name] = s.groups.Stage(name, stage)
+		}
+
+		stages[GROUP] = s.groups
+		s.order = append(s.order, GROUP)
+	}
+
+	// Client access control precedes validation when configured
+	// so that requests of denied clients are not evaluated
+	if s.cfg.ACL.Enabled() {
+		access, err := AccessControl(s.ctx, s.logger, s.cfg.ACL)
+		if err != nil {
+			return err
+		}
+
+		stages[ACCESS] = access
+		s.order = append([]Category{ACCESS}, s.order...)
+	}
+
+	for name, stage := range stages {
+		err = s.pipeline.Register(name, stage)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// clientGroups creates the lists and upstreams of the client groups.
+func (s *Server) clientGroups() (*Groups, error) {
+	groups, err := ClientGroups(s.ctx, s.logger, s.cfg.Local, s.cfg.Groups...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cg := range groups.groups {
+		upstream := s.upstream
+		if len(cg.Upstreams) > 0 {
+			upstream, err = s.upstreams(cg.Upstreams...)
+			if err != nil {
+				return nil, err
+			}
+
+			cg.upstream = upstream
+		}
+
+		cg.stages, err = s.lists(cg.Group, upstream)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return groups, nil
+}
+
+// route sends the requests which pass the pipeline to the upstreams of
+// their client group.
+func (s *Server) route(in <-chan *Request) {
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case req, ok := <-in:
+			if !ok {
+				return
+			}
+
+			select {
+			case <-s.ctx.Done():
+				return
+			case s.groups.upstream(req, s.upstream) <- req:
+			}
+		}
+	}
+}
+
+// Register adds a custom stage to the pipeline under the name, which is
+// then placed in the pipeline using Config.Pipeline.
+func (s *Server) Register(name Category, stage Interceptor) error {
+	return s.pipeline.Register(name, stage)
+}
+
+// Pauses returns the pauses of blocking, for pausing blocking without
+// the control interface.
+func (s *Server) Pauses() *Pauses {
+	return s.pauses
+}
+
+// control creates the listener of the control interface.
+func (s *Server) control(ctx context.Context) (Listener, error) {
+	h, err := ControlHandler(ctx, s.logger, s.pauses, s.cfg.ControlToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return ControlListener(s.logger, s.cfg.Control, h)
+}
+
+// Handler returns the dns handler which pushes requests into the
+// pipeline, for serving the pipeline with a custom dns.Server.
+func (s *Server) Handler() dns.Handler {
+	return dns.HandlerFunc(s.handler)
+}
+
+// build builds the pipeline on first use.
+func (s *Server) build() error {
+	s.once.Do(func() {
+		order := s.cfg.Pipeline
+		if len(order) == 0 {
+			order = DefaultPipeline
+		}
+
+		upstream := s.upstream
+		if s.groups != nil {
+			forward := make(chan *Request)
+			go s.route(forward)
+
+			upstream = forward
+		}
+
+		s.buildErr = s.pipeline.Build(
+			s.ctx,
+			s.requests,
+			upstream,
+			append(append([]Category{}, s.order...), order...)...,
+		)
+	})
+
+	return s.buildErr
+}
+
+// Serve builds the pipeline and serves the listeners until the context
+// is canceled.
+func (s *Server) Serve(ctx context.Context) error {
+	err := s.build()
+	if err != nil {
+		return err
+	}
+
+	var listeners []Listener
+	if len(s.cfg.Sockets) > 0 {
+		listeners, err = SocketListeners(
+			ctx,
+			s.logger,
+			s.Handler(),
+			s.cfg.TCP,
+			s.cfg.TLS,
+			s.cfg.Sockets...,
+		)
+	} else {
+		listeners, err = Listeners(
+			ctx,
+			s.logger,
+			s.Handler(),
+			s.cfg.TCP,
+			s.cfg.TLS,
+			s.cfg.Listen...,
+		)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if s.cfg.Control != "" {
+		l, err := s.control(ctx)
+		if err != nil {
+			return err
+		}
+
+		listeners = append(listeners, l)
+	}
+
+	if len(listeners) == 0 {
+		return errors.New("no listeners configured")
+	}
+
+	s.logger.Infow(
+		"dns service initialized",
+		"listeners", len(listeners),
+		"upstream", s.cfg.Upstreams,
+	)
+
+	return Serve(ctx, s.logger, listeners...)
+}
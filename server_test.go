@@ -0,0 +1,376 @@
+package void_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"go.avoid.dev/void"
+)
+
+func Test_Server(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := void.NewServer(ctx, &void.NOOPLogger{}, void.Config{
+		Sockets: []void.Socket{{Proto: void.UDP, PacketConn: pc}},
+		Local: []*void.Record{{
+			Pattern: "local.example.tld",
+			Type:    void.DIRECT,
+			IP:      net.ParseIP("192.168.0.1"),
+		}},
+		Block: []*void.Record{{
+			Pattern: "blocked.example.tld",
+			Type:    void.DIRECT,
+		}},
+		Pipeline: []void.Category{
+			"custom",
+			void.BLOCK,
+			void.LOCAL,
+			void.UPSTREAM,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// custom answers a single name with a TXT record from the client
+	// and server addresses of the request
+	err = srv.Register("custom", void.InterceptorFunc(func(
+		_ context.Context,
+		req *void.Request,
+	) (*void.Request, bool) {
+		if req.Record() != "custom.example.tld" {
+			return req, true
+		}
+
+		res := (&dns.Msg{}).SetReply(req.Msg())
+		res.Answer = append(res.Answer, &dns.TXT{
+			Hdr: dns.RR_Header{
+				Name:   req.Msg().Question[0].Name,
+				Rrtype: dns.TypeTXT,
+				Class:  dns.ClassINET,
+				Ttl:    void.DEFAULTTTL,
+			},
+			Txt: []string{req.Server(), req.Client()},
+		})
+
+		_ = req.Answer(res)
+		return nil, false
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.Serve(ctx)
+	}()
+
+	tests := map[string]struct {
+		name    string
+		qtype   uint16
+		rcode   int
+		answers int
+	}{
+		"custom": {
+			name:    "custom.example.tld.",
+			qtype:   dns.TypeTXT,
+			answers: 1,
+		},
+		"local": {
+			name:    "local.example.tld.",
+			qtype:   dns.TypeA,
+			answers: 1,
+		},
+		"blocked": {
+			name:  "blocked.example.tld.",
+			qtype: dns.TypeA,
+			rcode: dns.RcodeNameError,
+		},
+	}
+
+	c := &dns.Client{Net: string(void.UDP), Timeout: time.Second}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := (&dns.Msg{}).SetQuestion(test.name, test.qtype)
+
+			res, _, err := c.Exchange(req, pc.LocalAddr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.Rcode != test.rcode {
+				t.Fatalf(
+					"expected rcode %s, got %s",
+					dns.RcodeToString[test.rcode],
+					dns.RcodeToString[res.Rcode],
+				)
+			}
+
+			if len(res.Answer) != test.answers {
+				t.Fatalf("expected %d answers, got %d", test.answers, len(res.Answer))
+			}
+		})
+	}
+
+	cancel()
+
+	err = <-errs
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_NewServer_InvalidPipeline(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	srv, err := void.NewServer(ctx, &void.NOOPLogger{}, void.Config{
+		Listen: []void.ListenAddr{{
+			Proto: void.UDP,
+			IP:    net.ParseIP("127.0.0.1"),
+			Port:  0,
+		}},
+		Pipeline: []void.Category{void.UPSTREAM, void.BLOCK},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = srv.Serve(ctx)
+	if err == nil {
+		t.Fatal("expected error for stage after upstream")
+	}
+}
+
+func Test_Server_Groups(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	local := []*void.Record{{
+		Pattern: "local.example.tld",
+		Type:    void.DIRECT,
+		IP:      net.ParseIP("192.168.0.1"),
+	}}
+
+	srv, err := void.NewServer(ctx, &void.NOOPLogger{}, void.Config{
+		Sockets: []void.Socket{{Proto: void.UDP, PacketConn: pc}},
+		Local:   local,
+		Groups: []void.Group{{
+			Name:    "loopback",
+			Clients: []string{"127.0.0.0/8"},
+			Block:   local,
+			Mode:    &void.BlockMode{Action: void.NODATA},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.Serve(ctx)
+	}()
+
+	// The group replaces the global lists, blocking the local record
+	c := &dns.Client{Net: string(void.UDP), Timeout: time.Second}
+	req := (&dns.Msg{}).SetQuestion("local.example.tld.", dns.TypeA)
+
+	res, _, err := c.Exchange(req, pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Rcode != dns.RcodeSuccess || len(res.Answer) != 0 {
+		t.Fatalf("expected nodata response, got %s", res)
+	}
+
+	cancel()
+
+	err = <-errs
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_Server_Zones(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The upstream of the zone answers with a private address
+	zone := testUpstream(t, "10.0.0.2")
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := void.NewServer(ctx, &void.NOOPLogger{}, void.Config{
+		Sockets: []void.Socket{{Proto: void.UDP, PacketConn: pc}},
+		// The global upstream is not reachable
+		Upstreams: []string{"udp://127.0.0.1:1"},
+		Zones: []void.Zone{{
+			Domain:    "corp.example",
+			Upstreams: []string{zone},
+		}},
+		Rebind: &void.RebindConfig{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.Serve(ctx)
+	}()
+
+	// The domain and subdomains of the zone are forwarded to its upstream,
+	// and are allowed to resolve to private addresses
+	c := &dns.Client{Net: string(void.UDP), Timeout: time.Second}
+	for _, name := range []string{"corp.example.", "git.corp.example."} {
+		req := (&dns.Msg{}).SetQuestion(name, dns.TypeA)
+
+		res, _, err := c.Exchange(req, pc.LocalAddr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res.Rcode != dns.RcodeSuccess || len(res.Answer) != 1 {
+			t.Fatalf("expected answer from the zone upstream, got %s", res)
+		}
+	}
+
+	cancel()
+
+	err = <-errs
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testUpstream starts an upstream DNS server which answers every request
+// with an A record of the ip, returning the upstream address.
+func testUpstream(t *testing.T, ip string) string {
+	t.Helper()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &dns.Server{
+		PacketConn: pc,
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			res := (&dns.Msg{}).SetReply(r)
+			res.Answer = append(res.Answer, &dns.A{
+				Hdr: dns.RR_Header{
+					Name:   r.Question[0].Name,
+					Rrtype: dns.TypeA,
+					Class:  dns.ClassINET,
+					Ttl:    60,
+				},
+				A: net.ParseIP(ip),
+			})
+
+			_ = w.WriteMsg(res)
+		}),
+	}
+
+	go func() {
+		_ = srv.ActivateAndServe()
+	}()
+
+	t.Cleanup(func() {
+		_ = srv.Shutdown()
+	})
+
+	return "udp://" + pc.LocalAddr().String()
+}
+
+func Test_Server_Pause_Cache(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := void.NewServer(ctx, &void.NOOPLogger{}, void.Config{
+		Sockets:   []void.Socket{{Proto: void.UDP, PacketConn: pc}},
+		Upstreams: []string{testUpstream(t, "93.184.216.34")},
+		Block: []*void.Record{{
+			Pattern: "blocked.example.tld",
+			Type:    void.DIRECT,
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.Serve(ctx)
+	}()
+
+	c := &dns.Client{Net: string(void.UDP), Timeout: time.Second}
+	query := func(rcode, answers int) {
+		t.Helper()
+
+		req := (&dns.Msg{}).SetQuestion("blocked.example.tld.", dns.TypeA)
+
+		res, _, err := c.Exchange(req, pc.LocalAddr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res.Rcode != rcode {
+			t.Fatalf(
+				"expected rcode %s, got %s",
+				dns.RcodeToString[rcode],
+				dns.RcodeToString[res.Rcode],
+			)
+		}
+
+		if len(res.Answer) != answers {
+			t.Fatalf("expected %d answers, got %d", answers, len(res.Answer))
+		}
+	}
+
+	// Each step is answered by the cache when the previous response
+	// is cached
+	query(dns.RcodeNameError, 0)
+
+	_, err = srv.Pauses().Pause("", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query(dns.RcodeSuccess, 1)
+
+	if !srv.Pauses().Resume("") {
+		t.Fatal("expected resume")
+	}
+
+	query(dns.RcodeNameError, 0)
+
+	cancel()
+
+	err = <-errs
+	if err != nil {
+		t.Fatal(err)
+	}
+}
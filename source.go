@@ -1,4 +1,4 @@
-package main
+package void
 
 import (
 	"context"
@@ -19,6 +19,12 @@ type Source struct {
 	Sync     *time.Duration
 	Category string
 	Tags     []string
+
+	// Schedule limits when the records of the source are applied.
+	Schedule *Schedule
+
+	// Mode is the response to requests for blocked records.
+	Mode *BlockMode
 }
 
 type tee struct {
@@ -151,6 +157,11 @@ func (s *Source) Local(
 			src.Tags...,
 		)
 
+		for _, e := range entries {
+			e.Schedule = src.Schedule
+			e.Mode = src.Mode
+		}
+
 		logger.Infow(
 			"local source loaded",
 			"entries", len(entries),
@@ -196,6 +207,11 @@ func (s *Source) Remote(
 			src.Tags...,
 		)
 
+		for _, e := range entries {
+			e.Schedule = src.Schedule
+			e.Mode = src.Mode
+		}
+
 		logger.Infow(
 			"remote source loaded",
 			"entries", len(entries),
@@ -229,6 +245,8 @@ func readSrcs(parent *Source, body io.ReadCloser) []*Source {
 			Sync:     parent.Sync,
 			Category: parent.Category,
 			Tags:     parent.Tags,
+			Schedule: parent.Schedule,
+			Mode:     parent.Mode,
 		})
 	}
 
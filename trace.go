@@ -0,0 +1,191 @@
+package void
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+	"go.atomizer.io/stream"
+)
+
+// requestID is the source of the request ids assigned in Convert.
+//
+//nolint:gochecknoglobals // process wide counter
+var requestID atomic.Uint64
+
+// Decision is the outcome of a pipeline stage for a request.
+type Decision string
+
+const (
+	// CONTINUE passed the request to the next stage.
+	CONTINUE Decision = "continue"
+
+	// STOP stopped the request without answering it, such as
+	// handing it to the upstreams or dropping it.
+	STOP Decision = "stop"
+
+	// ANSWER wrote the response to the request.
+	ANSWER Decision = "answer"
+)
+
+// Span is the time a request spent in a stage of the pipeline, where
+// Enter and Exit are the durations since the request was received.
+// A span without a decision had not exited when the response was written.
+type Span struct {
+	Stage    Category      `json:"stage"`
+	Enter    time.Duration `json:"enter"`
+	Exit     time.Duration `json:"exit,omitempty"`
+	Decision Decision      `json:"decision,omitempty"`
+}
+
+// Trace is the path of a request through the pipeline, emitted when the
+// response is written.
+type Trace struct {
+	ID       uint64        `json:"id"`
+	Name     string        `json:"name,omitempty"`
+	Type     string        `json:"type,omitempty"`
+	Client   string        `json:"client,omitempty"`
+	Server   string        `json:"server,omitempty"`
+	Group    string        `json:"group,omitempty"`
+	Rcode    string        `json:"rcode"`
+	Answers  int           `json:"answers"`
+	Duration time.Duration `json:"duration"`
+	Stages   []Span        `json:"stages"`
+
+	// Limited is the action of the rate limit which refused, dropped, or
+	// truncated (slipped) the response, see RateLimitConfig.
+	Limited string `json:"limited,omitempty"`
+}
+
+func (t *Trace) String() string {
+	stages := make([]string, 0, len(t.Stages))
+	for _, s := range t.Stages {
+		stages = append(stages, fmt.Sprintf(
+			"%s:%s:%s",
+			s.Stage,
+			s.Exit-s.Enter,
+			s.Decision,
+		))
+	}
+
+	rcode := t.Rcode
+	if t.Limited != "" {
+		rcode = fmt.Sprintf("%s(%s)", rcode, t.Limited)
+	}
+
+	return fmt.Sprintf(
+		"%d %s %s %s %s [%s]",
+		t.ID,
+		t.Name,
+		t.Type,
+		rcode,
+		t.Duration,
+		strings.Join(stages, " "),
+	)
+}
+
+func (t *Trace) Event() string {
+	return t.String()
+}
+
+// traced records a span on the request for every call of the stage.
+func traced(
+	name Category,
+	f stream.InterceptFunc[*Request, *Request],
+) stream.InterceptFunc[*Request, *Request] {
+	return func(ctx context.Context, req *Request) (*Request, bool) {
+		span := req.enter(name)
+
+		out, ok := f(ctx, req)
+
+		req.exit(span, ok)
+		return out, ok
+	}
+}
+
+// enterUpstream opens the upstream span of requests handed to the
+// upstreams, which is closed when the response is written.
+func enterUpstream(_ context.Context, req *Request) (*Request, bool) {
+	req.enter(UPSTREAM)
+	return req, true
+}
+
+// enter opens a span for the stage and returns its index.
+func (r *Request) enter(stage Category) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.spans = append(r.spans, Span{
+		Stage: stage,
+		Enter: time.Since(r.start),
+	})
+
+	return len(r.spans) - 1
+}
+
+// exit closes the span unless it was closed when the response
+// was written.
+func (r *Request) exit(span int, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.spans[span].Decision != "" {
+		return
+	}
+
+	r.spans[span].Exit = time.Since(r.start)
+	r.spans[span].Decision = STOP
+	if ok {
+		r.spans[span].Decision = CONTINUE
+	}
+}
+
+// trace closes the most recently opened span as the stage which answered
+// the request and returns the trace of the response.
+func (r *Request) trace(res *dns.Msg) *Trace {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	elapsed := time.Since(r.start)
+
+	for i := len(r.spans) - 1; i >= 0; i-- {
+		if r.spans[i].Decision == "" {
+			r.spans[i].Exit = elapsed
+			r.spans[i].Decision = ANSWER
+			break
+		}
+	}
+
+	t := &Trace{
+		ID:       r.id,
+		Client:   r.client,
+		Server:   r.server,
+		Group:    r.group,
+		Rcode:    dns.RcodeToString[res.Rcode],
+		Answers:  len(res.Answer),
+		Duration: elapsed,
+		Stages:   append([]Span{}, r.spans...),
+	}
+
+	t.question(res)
+
+	return t
+}
+
+// question sets the name and type of the trace from the question of the
+// response.
+func (t *Trace) question(res *dns.Msg) {
+	// Responses to malformed requests may not include a question
+	if len(res.Question) > 0 {
+		t.Name = res.Question[0].Name
+		t.Type = dns.Type(res.Question[0].Qtype).String()
+	}
+}
+
+// ID returns the id assigned to the request when it was received.
+func (r *Request) ID() uint64 {
+	return r.id
+}
@@ -0,0 +1,157 @@
+package void
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// traceLogger captures the traces emitted by the metric writer.
+type traceLogger struct {
+	NOOPLogger
+	traces chan *Trace
+}
+
+func (l *traceLogger) Debugw(_ string, keysAndValues ...interface{}) {
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		if t, ok := keysAndValues[i+1].(*Trace); ok {
+			l.traces <- t
+		}
+	}
+}
+
+func Test_Pipeline_Trace(t *testing.T) {
+	pass := InterceptorFunc(func(
+		_ context.Context,
+		req *Request,
+	) (*Request, bool) {
+		return req, true
+	})
+
+	answer := InterceptorFunc(func(
+		_ context.Context,
+		req *Request,
+	) (*Request, bool) {
+		_ = req.Fail(dns.RcodeNameError)
+
+		// Exit after the response is written
+		time.Sleep(time.Millisecond)
+		return nil, false
+	})
+
+	tests := map[string]struct {
+		order    []Category
+		rcode    int
+		expected []Span
+	}{
+		"answered": {
+			order: []Category{"pass", "answer", "pass2", UPSTREAM},
+			rcode: dns.RcodeNameError,
+			expected: []Span{
+				{Stage: "pass", Decision: CONTINUE},
+				{Stage: "answer", Decision: ANSWER},
+			},
+		},
+		"upstream": {
+			order: []Category{"pass", "pass2", UPSTREAM},
+			rcode: dns.RcodeSuccess,
+			expected: []Span{
+				{Stage: "pass", Decision: CONTINUE},
+				{Stage: "pass2", Decision: CONTINUE},
+				{Stage: UPSTREAM, Decision: ANSWER},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			logger := &traceLogger{traces: make(chan *Trace, 1)}
+
+			p, err := NewPipeline(logger)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for name, stage := range map[Category]Interceptor{
+				"pass":   pass,
+				"pass2":  pass,
+				"answer": answer,
+			} {
+				err = p.Register(name, stage)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			in := make(chan *Request)
+			upstream := make(chan *Request)
+
+			err = p.Build(ctx, in, upstream, test.order...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tw := &TestWriter{}
+			req := NewRequest(
+				ctx,
+				tw,
+				Question(t, "test.example.tld.", dns.TypeA),
+				"127.0.0.1:53",
+				"127.0.0.1:5353",
+			)
+			req.w = &metricWriter{logger: logger, req: req, next: tw.WriteMsg}
+
+			in <- req
+
+			var tr *Trace
+			select {
+			case <-time.After(time.Second):
+				t.Fatal("expected trace")
+			case tr = <-logger.traces:
+			case up := <-upstream:
+				up, _ = enterUpstream(ctx, up)
+				_ = up.Answer((&dns.Msg{}).SetReply(up.Msg()))
+				tr = <-logger.traces
+			}
+
+			if tr.ID != req.ID() {
+				t.Fatalf("expected id %d, got %d", req.ID(), tr.ID)
+			}
+
+			if tr.Rcode != dns.RcodeToString[test.rcode] {
+				t.Fatalf(
+					"expected rcode %s, got %s",
+					dns.RcodeToString[test.rcode],
+					tr.Rcode,
+				)
+			}
+
+			if len(tr.Stages) != len(test.expected) {
+				t.Fatalf("expected %d stages, got %+v", len(test.expected), tr.Stages)
+			}
+
+			for i, span := range tr.Stages {
+				if span.Stage != test.expected[i].Stage ||
+					span.Decision != test.expected[i].Decision {
+					t.Fatalf(
+						"expected stage %d to be %s:%s, got %s:%s",
+						i,
+						test.expected[i].Stage,
+						test.expected[i].Decision,
+						span.Stage,
+						span.Decision,
+					)
+				}
+
+				if span.Exit < span.Enter {
+					t.Fatalf("stage %s exited before entering", span.Stage)
+				}
+			}
+		})
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package void
 
 import (
 	"context"
@@ -20,7 +20,7 @@ import (
 
 const (
 	portReg  = `(\:{1}[0-9]{1,5}){0,1}`
-	protoReg = `(tcp|udp|tcp-tls){0,1}(?:\:\/\/){0,1}`
+	protoReg = `(tcp|udp|tcp-tls|quic){0,1}(?:\:\/\/){0,1}`
 	ipv4Reg  = `(?:[0-9]{1,3}\.[0-9]{1,3}\.[0-9]{1,3}\.[0-9]{1,3})`
 	//nolint:lll
 	ipv6Reg  = `(?:(?:[0-9a-fA-F]{1,4}:){7,7}[0-9a-fA-F]{1,4}|(?:[0-9a-fA-F]{1,4}:){1,7}:|(?:[0-9a-fA-F]{1,4}:){1,6}:[0-9a-fA-F]{1,4}|(?:[0-9a-fA-F]{1,4}:){1,5}(?::[0-9a-fA-F]{1,4}){1,2}|(?:[0-9a-fA-F]{1,4}:){1,4}(?::[0-9a-fA-F]{1,4}){1,3}|(?:[0-9a-fA-F]{1,4}:){1,3}(?::[0-9a-fA-F]{1,4}){1,4}|(?:[0-9a-fA-F]{1,4}:){1,2}(?::[0-9a-fA-F]{1,4}){1,5}|[0-9a-fA-F]{1,4}:(?:(?::[0-9a-fA-F]{1,4}){1,6})|:(?:(?::[0-9a-fA-F]{1,4}){1,7}|:)|fe80:(?::[0-9a-fA-F]{0,4}){0,4}%[0-9a-zA-Z]{1,}|::(?:ffff(?::0{1,4}){0,1}:){0,1}(?:(?:25[0-5]|(?:2[0-4]|1{0,1}[0-9]){0,1}[0-9])\.){3,3}(?:25[0-5]|(?:2[0-4]|1{0,1}[0-9]){0,1}[0-9])|(?:[0-9a-fA-F]{1,4}:){1,4}:(?:(?:25[0-5]|(?:2[0-4]|1{0,1}[0-9]){0,1}[0-9])\.){3,3}(?:25[0-5]|(?:2[0-4]|1{0,1}[0-9]){0,1}[0-9]))`
@@ -34,6 +34,40 @@ var addrReg = regexp.MustCompile(
 	fmt.Sprintf(`^%s(%s|%s)%s$`, protoReg, ipv4Reg, ipv6Reg, portReg),
 )
 
+// parseAddr parses an address in the format <proto>://<server>[:<port>]
+// using the provided expression. The protocol and port are empty when
+// they are not included in the address.
+func parseAddr(
+	reg *regexp.Regexp,
+	address string,
+) (Protocol, net.IP, int, error) {
+	matches := reg.FindStringSubmatch(address)
+	if len(matches) != matchLen {
+		return "", nil, 0, fmt.Errorf("invalid address [%s]", address)
+	}
+
+	ip := net.ParseIP(strings.Trim(matches[2], "[]"))
+	if ip == nil {
+		return "", nil, 0, fmt.Errorf("invalid address [%s]", address)
+	}
+
+	var port int
+	p := strings.TrimPrefix(matches[3], ":")
+	if p != "" {
+		var err error
+		port, err = strconv.Atoi(p)
+		if err != nil {
+			return "", nil, 0, err
+		}
+
+		if port < 1 || port > 65535 {
+			return "", nil, 0, fmt.Errorf("invalid port [%s]", matches[3])
+		}
+	}
+
+	return Protocol(matches[1]), ip, port, nil
+}
+
 // Protocol is a type alias of string for categorizing
 // protocols for a DNS server.
 type Protocol string
@@ -47,8 +81,23 @@ const (
 
 	// TLS is the network type for TLS over TCP.
 	TLS Protocol = "tcp-tls"
+
+	// HTTPS is the network type for DNS-over-HTTPS.
+	HTTPS Protocol = "https"
+
+	// QUIC is the network type for DNS-over-QUIC.
+	QUIC Protocol = "quic"
 )
 
+// exchanger exchanges a DNS request with the server at the address.
+type exchanger interface {
+	ExchangeContext(
+		ctx context.Context,
+		req *dns.Msg,
+		address string,
+	) (*dns.Msg, time.Duration, error)
+}
+
 // TLSConfig load a preset tls configuration adding a custom CA certificate
 // to the system trust store if provided.
 func TLSConfig(caCert []byte) (*tls.Config, error) {
@@ -97,51 +146,48 @@ func Up(
 	upstreams := make([]*Upstream, 0, len(addresses))
 
 	for _, address := range addresses {
-		matches := addrReg.FindStringSubmatch(address)
-		if len(matches) != matchLen {
-			return nil, fmt.Errorf("invalid address [%s]", address)
+		proto, ip, port, err := parseAddr(addrReg, address)
+		if err != nil {
+			return nil, err
 		}
 
-		proto := UDP
-		if matches[1] != "" {
-			proto = Protocol(matches[1])
+		if proto == "" {
+			proto = UDP
 		}
 
-		port := defaultPort
-		p := strings.TrimPrefix(matches[3], ":")
-		if p != "" {
-			newport, err := strconv.Atoi(p)
-			if err != nil {
-				return nil, err
+		if port == 0 {
+			port = DefaultPort
+			if proto == QUIC {
+				port = DefaultTLSPort
 			}
-
-			if newport < 1 || newport > 65535 {
-				return nil, fmt.Errorf("invalid port [%s]", matches[3])
-			}
-
-			port = newport
 		}
 
 		// load the appropriate tls configuration
 		// if the network is TLS
 		var tlsConfig *tls.Config
-		if proto == TLS {
+		if proto == TLS || proto == QUIC {
 			tlsConfig, err = TLSConfig(nil)
 			if err != nil {
 				return nil, err
 			}
 		}
 
+		var client exchanger = &dns.Client{
+			Net:       string(proto),
+			TLSConfig: tlsConfig,
+		}
+
+		if proto == QUIC {
+			client = newQUICClient(tlsConfig, time.Minute)
+		}
+
 		u := &Upstream{
 			proto:     proto,
-			address:   net.ParseIP(matches[2]),
+			address:   ip,
 			port:      uint16(port),
 			logger:    logger,
 			reconnect: time.Minute,
-			client: &dns.Client{
-				Net:       string(proto),
-				TLSConfig: tlsConfig,
-			},
+			client:    client,
 		}
 
 		// Initialize the upstream connection
@@ -166,10 +212,11 @@ type Upstream struct {
 	// 		"udp"
 	// 		"tcp"
 	// 		"tcp-tls"
+	// 		"quic"
 	proto Protocol
 
 	// Client instance
-	client *dns.Client
+	client exchanger
 
 	// upstream connection
 
@@ -237,7 +284,12 @@ func (u *Upstream) Intercept(
 			return
 		}
 
-		err = req.w.WriteMsg(resp)
+		// Only the first upstream to respond answers the request
+		err = req.Answer(resp)
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+
 		if err != nil {
 			u.logger.Errorw(
 				"failed to write response",
@@ -1,4 +1,4 @@
-package main
+package void
 
 import (
 	"context"
@@ -57,6 +57,14 @@ func Test_Up(t *testing.T) {
 				port:    53,
 			}},
 		},
+		"valid-ipv4-no-port-quic": {
+			address: "quic://1.1.1.1",
+			expected: []Upstream{{
+				proto:   QUIC,
+				address: cloudflareIpv4,
+				port:    853,
+			}},
+		},
 		"valid-ipv4-no-port-udp": {
 			address: "udp://1.1.1.1",
 			expected: []Upstream{{
@@ -132,6 +140,14 @@ func Test_Up(t *testing.T) {
 				port:    53,
 			}},
 		},
+		"valid-ipv6-no-port-quic": {
+			address: "quic://2606:4700:4700::1111",
+			expected: []Upstream{{
+				proto:   QUIC,
+				address: cloudflareIpv6,
+				port:    853,
+			}},
+		},
 		"valid-ipv6-no-port-udp": {
 			address: "udp://2606:4700:4700::1111",
 			expected: []Upstream{{
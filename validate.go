@@ -0,0 +1,100 @@
+package void
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/miekg/dns"
+)
+
+// NewValidator creates the validation stage which must be the first stage
+// of the pipeline so that the following stages can safely assume the
+// request has exactly one well formed question.
+func NewValidator(ctx context.Context, logger Logger) (*Validator, error) {
+	err := checkNil(ctx, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Validator{
+		ctx:    ctx,
+		logger: logger,
+	}, nil
+}
+
+// Validator answers malformed requests with FORMERR and requests for
+// unsupported operations with NOTIMP rather than passing them down the
+// pipeline.
+type Validator struct {
+	ctx    context.Context
+	logger Logger
+}
+
+func (v *Validator) Intercept(
+	ctx context.Context,
+	req *Request,
+) (*Request, bool) {
+	rcode, err := validate(req.r)
+	if err == nil {
+		return req, true
+	}
+
+	v.logger.Debugw(
+		"invalid request",
+		"category", VALIDATE,
+		"rcode", dns.RcodeToString[rcode],
+		"client", req.client,
+		"server", req.server,
+		"error", err,
+	)
+
+	err = req.Fail(rcode)
+	if err != nil {
+		v.logger.Errorw(
+			"failed to respond",
+			"category", VALIDATE,
+			"rcode", dns.RcodeToString[rcode],
+			"client", req.client,
+			"server", req.server,
+			"error", err,
+		)
+	}
+
+	return nil, false
+}
+
+// validate returns the rcode to respond with along with the reason when
+// the request is not a valid query.
+func validate(msg *dns.Msg) (int, error) {
+	if msg.Response {
+		return dns.RcodeFormatError, errors.New("message is a response")
+	}
+
+	if msg.Opcode != dns.OpcodeQuery {
+		return dns.RcodeNotImplemented, fmt.Errorf(
+			"unsupported opcode [%s]",
+			dns.OpcodeToString[msg.Opcode],
+		)
+	}
+
+	if len(msg.Question) != 1 {
+		return dns.RcodeFormatError, fmt.Errorf(
+			"expected 1 question, got %d",
+			len(msg.Question),
+		)
+	}
+
+	name := msg.Question[0].Name
+	if !dns.IsFqdn(name) {
+		return dns.RcodeFormatError, fmt.Errorf("name [%s] is not fully qualified", name)
+	}
+
+	// Checks the label and name length limits of RFC 1035
+	_, ok := dns.IsDomainName(name)
+	if !ok {
+		return dns.RcodeFormatError, fmt.Errorf("invalid name [%s]", name)
+	}
+
+	return dns.RcodeSuccess, nil
+}
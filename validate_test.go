@@ -0,0 +1,143 @@
+package void
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func Test_Validator_Intercept(t *testing.T) {
+	pctx, pcancel := context.WithCancel(context.Background())
+	defer pcancel()
+
+	tests := map[string]struct {
+		msg   func(t *testing.T) *dns.Msg
+		rcode int
+		pass  bool
+	}{
+		"valid": {
+			msg: func(t *testing.T) *dns.Msg {
+				return Question(t, "test.example.tld.", dns.TypeA)
+			},
+			pass: true,
+		},
+		"no-question": {
+			msg: func(t *testing.T) *dns.Msg {
+				return &dns.Msg{}
+			},
+			rcode: dns.RcodeFormatError,
+		},
+		"multiple-questions": {
+			msg: func(t *testing.T) *dns.Msg {
+				m := Question(t, "test.example.tld.", dns.TypeA)
+				m.Question = append(m.Question, dns.Question{
+					Name:   "other.example.tld.",
+					Qtype:  dns.TypeA,
+					Qclass: dns.ClassINET,
+				})
+
+				return m
+			},
+			rcode: dns.RcodeFormatError,
+		},
+		"label-too-long": {
+			msg: func(t *testing.T) *dns.Msg {
+				return Question(t, strings.Repeat("a", 64)+".tld.", dns.TypeA)
+			},
+			rcode: dns.RcodeFormatError,
+		},
+		"name-too-long": {
+			msg: func(t *testing.T) *dns.Msg {
+				return Question(t, strings.Repeat("abcdefghi.", 26), dns.TypeA)
+			},
+			rcode: dns.RcodeFormatError,
+		},
+		"empty-label": {
+			msg: func(t *testing.T) *dns.Msg {
+				return Question(t, "test..tld.", dns.TypeA)
+			},
+			rcode: dns.RcodeFormatError,
+		},
+		"not-fqdn": {
+			msg: func(t *testing.T) *dns.Msg {
+				m := Question(t, "test.example.tld.", dns.TypeA)
+				m.Question[0].Name = "test.example.tld"
+
+				return m
+			},
+			rcode: dns.RcodeFormatError,
+		},
+		"response": {
+			msg: func(t *testing.T) *dns.Msg {
+				m := Question(t, "test.example.tld.", dns.TypeA)
+				m.Response = true
+
+				return m
+			},
+			rcode: dns.RcodeFormatError,
+		},
+		"notify": {
+			msg: func(t *testing.T) *dns.Msg {
+				return (&dns.Msg{}).SetNotify("example.tld.")
+			},
+			rcode: dns.RcodeNotImplemented,
+		},
+		"update": {
+			msg: func(t *testing.T) *dns.Msg {
+				return (&dns.Msg{}).SetUpdate("example.tld.")
+			},
+			rcode: dns.RcodeNotImplemented,
+		},
+	}
+
+	v, err := NewValidator(pctx, &NOOPLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(pctx)
+			defer cancel()
+
+			w := &TestWriter{}
+			req := &Request{
+				ctx:    ctx,
+				cancel: cancel,
+				w:      w,
+				r:      test.msg(t),
+			}
+
+			_, pass := v.Intercept(ctx, req)
+			if pass != test.pass {
+				t.Fatalf("expected pass %v, got %v", test.pass, pass)
+			}
+
+			if test.pass {
+				if w.response != nil {
+					t.Fatal("expected no response")
+				}
+
+				return
+			}
+
+			if w.response == nil {
+				t.Fatal("expected response")
+			}
+
+			if w.response.Rcode != test.rcode {
+				t.Fatalf(
+					"expected rcode %s, got %s",
+					dns.RcodeToString[test.rcode],
+					dns.RcodeToString[w.response.Rcode],
+				)
+			}
+
+			if w.response.Id != req.r.Id {
+				t.Fatalf("expected id %d, got %d", req.r.Id, w.response.Id)
+			}
+		})
+	}
+}